@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// auditEvent is one access decision for an /api/apps request
+type auditEvent struct {
+	Tag        string    `json:"tag"`
+	Time       time.Time `json:"time"`
+	User       string    `json:"user"`
+	Groups     []string  `json:"groups"`
+	Granted    int       `json:"granted"`
+	Denied     int       `json:"denied"`
+	RemoteAddr string    `json:"remote_addr"`
+	RequestID  string    `json:"request_id,omitempty"`
+}
+
+// audit writes access decisions to the sink configured by AUDIT_LOG, kept apart
+// from operational logs; a nil writer disables auditing
+var audit = &auditLogger{}
+
+type auditLogger struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// openAuditSink configures the audit sink: "stdout", "stderr" or a file path to append to
+func openAuditSink(target string) error {
+	var w io.Writer
+	switch target {
+	case "":
+		return nil
+	case "stdout":
+		w = os.Stdout
+	case "stderr":
+		w = os.Stderr
+	default:
+		f, err := os.OpenFile(target, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o640)
+		if err != nil {
+			return fmt.Errorf("open audit log: %w", err)
+		}
+		w = f
+	}
+
+	audit.mu.Lock()
+	audit.w = w
+	audit.mu.Unlock()
+	log.Printf("Audit logging enabled (sink=%s)", target)
+	return nil
+}
+
+// record emits one audit event for the request if auditing is enabled
+func (a *auditLogger) record(r *http.Request, groups []string, granted, denied int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.w == nil {
+		return
+	}
+
+	event := auditEvent{
+		Tag:        "audit",
+		Time:       time.Now().UTC(),
+		User:       auditUser(r),
+		Groups:     groups,
+		Granted:    granted,
+		Denied:     denied,
+		RemoteAddr: r.RemoteAddr,
+		RequestID:  requestID(r.Context()),
+	}
+	if err := json.NewEncoder(a.w).Encode(event); err != nil {
+		log.Printf("ERROR writing audit event: %v", err)
+	}
+}
+
+// auditUser returns the identity the auth proxy forwarded, if any
+func auditUser(r *http.Request) string {
+	if user := getUserName(r); user != "" {
+		return user
+	}
+	return r.Header.Get("X-Forwarded-Email")
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// badges holds the latest badge counts fetched in the background
+var badges = newBadgeStore()
+
+// badgeStore keeps the last successfully fetched count per badge source
+type badgeStore struct {
+	mu     sync.RWMutex
+	counts map[string]int
+	client *http.Client
+}
+
+func newBadgeStore() *badgeStore {
+	return &badgeStore{
+		counts: make(map[string]int),
+		client: &http.Client{},
+	}
+}
+
+// badgeKey identifies a badge source by its URL and JSON path
+func badgeKey(app App) string {
+	return app.BadgeURL + "#" + app.BadgePath
+}
+
+// apply copies the known badge counts onto apps that declare a badge URL
+func (b *badgeStore) apply(apps []App) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for i := range apps {
+		if apps[i].BadgeURL == "" {
+			continue
+		}
+		apps[i].Badge = b.counts[badgeKey(apps[i])]
+	}
+}
+
+// run refreshes every badge on each tick until the process exits
+func (b *badgeStore) run(interval time.Duration) {
+	b.refresh()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		b.refresh()
+	}
+}
+
+// refresh fetches all badges concurrently and drops counts for apps that disappeared
+func (b *badgeStore) refresh() {
+	apps, err := discoverApps(context.Background())
+	if err != nil {
+		log.Printf("ERROR discovering apps for badges: %v", err)
+		return
+	}
+
+	// counts is shared with the pool workers, so every write holds mu; the loop
+	// dedupes keys in its own seen set
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	counts := make(map[string]int)
+	seen := make(map[string]bool)
+
+	for _, app := range apps {
+		if app.BadgeURL == "" {
+			continue
+		}
+		key := badgeKey(app)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		b.mu.RLock()
+		previous := b.counts[key]
+		b.mu.RUnlock()
+		mu.Lock()
+		counts[key] = previous
+		mu.Unlock()
+
+		app, key := app, key
+		wg.Add(1)
+		backgroundFetches.submit("badge", func() {
+			defer wg.Done()
+			count, err := b.fetch(app.BadgeURL, app.BadgePath)
+			if err != nil {
+				// Keep the previous count so a transient failure doesn't blank the tile
+				log.Printf("WARNING: Badge fetch failed: title=%s url=%s err=%v", app.Title, app.BadgeURL, err)
+				return
+			}
+			mu.Lock()
+			counts[key] = count
+			mu.Unlock()
+		})
+	}
+	wg.Wait()
+
+	b.mu.Lock()
+	b.counts = counts
+	b.mu.Unlock()
+
+	if debugMode {
+		log.Printf("DEBUG: Refreshed %d badges", len(counts))
+	}
+}
+
+// fetch retrieves a badge URL and extracts the integer at the given JSON path
+func (b *badgeStore) fetch(url, path string) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var body interface{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil {
+		return 0, err
+	}
+
+	return extractBadgeCount(body, path)
+}
+
+// extractBadgeCount walks a dot-separated path (numeric segments index arrays) and
+// converts the value found into a count; arrays count their elements
+func extractBadgeCount(value interface{}, path string) (int, error) {
+	if path != "" {
+		for _, segment := range strings.Split(path, ".") {
+			switch v := value.(type) {
+			case map[string]interface{}:
+				next, ok := v[segment]
+				if !ok {
+					return 0, fmt.Errorf("key %q not found", segment)
+				}
+				value = next
+			case []interface{}:
+				index, err := strconv.Atoi(segment)
+				if err != nil || index < 0 || index >= len(v) {
+					return 0, fmt.Errorf("invalid index %q", segment)
+				}
+				value = v[index]
+			default:
+				return 0, fmt.Errorf("cannot descend into %q", segment)
+			}
+		}
+	}
+
+	switch v := value.(type) {
+	case float64:
+		return int(v), nil
+	case string:
+		return strconv.Atoi(strings.TrimSpace(v))
+	case []interface{}:
+		return len(v), nil
+	default:
+		return 0, fmt.Errorf("value at %q is not a number", path)
+	}
+}
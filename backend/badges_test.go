@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestBadgeRefreshParallel fetches many badges on several workers, with duplicate
+// keys and failing sources mixed in; run it with -race
+func TestBadgeRefreshParallel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/badge/"))
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintf(w, `{"data":{"count":%d}}`, n)
+	}))
+	defer server.Close()
+	useFetchPool(t, 8)
+
+	var apps []App
+	for i := 0; i < 50; i++ {
+		apps = append(apps, App{ID: fmt.Sprint("app", i), Title: fmt.Sprint("App ", i), BadgeURL: fmt.Sprintf("%s/badge/%d", server.URL, i), BadgePath: "data.count"})
+	}
+	// Duplicate keys are fetched once, failing ones keep the previous count
+	apps = append(apps, App{ID: "dup", Title: "Dup", BadgeURL: server.URL + "/badge/0", BadgePath: "data.count"})
+	apps = append(apps, App{ID: "broken", Title: "Broken", BadgeURL: server.URL + "/badge/broken", BadgePath: "data.count"})
+	seedApps(t, apps)
+
+	b := newBadgeStore()
+	b.counts[badgeKey(apps[len(apps)-1])] = 7
+
+	for round := 0; round < 3; round++ {
+		b.refresh()
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if len(b.counts) != 51 {
+		t.Errorf("got %d counts, want 51", len(b.counts))
+	}
+	for i := 0; i < 50; i++ {
+		if got := b.counts[badgeKey(apps[i])]; got != i {
+			t.Errorf("badge %d = %d, want %d", i, got, i)
+		}
+	}
+	if got := b.counts[badgeKey(apps[len(apps)-1])]; got != 7 {
+		t.Errorf("failing badge = %d, want the previous count 7", got)
+	}
+}
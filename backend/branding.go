@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// portalBranding is the portal metadata served by /api/config so the frontend can be
+// rebranded without a rebuild
+type portalBranding struct {
+	Title       string `json:"title" yaml:"title"`
+	LogoURL     string `json:"logoUrl,omitempty" yaml:"logoUrl"`
+	Theme       string `json:"theme" yaml:"theme"`
+	AccentColor string `json:"accentColor,omitempty" yaml:"accentColor"`
+}
+
+// branding holds the PORTAL_* env overrides; in demo mode the config file's top
+// level fills in whatever they leave empty
+var branding portalBranding
+
+// defaultBranding matches what the frontend shows without any configuration
+var defaultBranding = portalBranding{Title: "Redval Server", Theme: "dark"}
+
+var accentColorPattern = regexp.MustCompile(`^#(?:[0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// problems lists the values that would be dropped by sanitized
+func (b portalBranding) problems() []string {
+	var problems []string
+	if b.LogoURL != "" && !validLogoURL(b.LogoURL) {
+		problems = append(problems, fmt.Sprintf("logo URL %q must be http(s), data:image or a path starting with /", b.LogoURL))
+	}
+	if b.Theme != "" && b.Theme != "light" && b.Theme != "dark" {
+		problems = append(problems, fmt.Sprintf("theme %q must be light or dark", b.Theme))
+	}
+	if b.AccentColor != "" && !accentColorPattern.MatchString(b.AccentColor) {
+		problems = append(problems, fmt.Sprintf("accent color %q must be #rgb or #rrggbb", b.AccentColor))
+	}
+	return problems
+}
+
+// sanitized drops invalid values, which the frontend would otherwise inject into
+// its styles and image sources
+func (b portalBranding) sanitized() portalBranding {
+	if b.LogoURL != "" && !validLogoURL(b.LogoURL) {
+		b.LogoURL = ""
+	}
+	if b.Theme != "light" && b.Theme != "dark" {
+		b.Theme = ""
+	}
+	if !accentColorPattern.MatchString(b.AccentColor) {
+		b.AccentColor = ""
+	}
+	return b
+}
+
+// merged fills b's empty fields from fallback
+func (b portalBranding) merged(fallback portalBranding) portalBranding {
+	if b.Title == "" {
+		b.Title = fallback.Title
+	}
+	if b.LogoURL == "" {
+		b.LogoURL = fallback.LogoURL
+	}
+	if b.Theme == "" {
+		b.Theme = fallback.Theme
+	}
+	if b.AccentColor == "" {
+		b.AccentColor = fallback.AccentColor
+	}
+	return b
+}
+
+// validLogoURL accepts http(s) URLs, base64 data:image URLs like app icons, and paths
+// on the portal itself
+func validLogoURL(logo string) bool {
+	switch {
+	case strings.HasPrefix(logo, "/"):
+		return !strings.HasPrefix(logo, "//")
+	case strings.HasPrefix(logo, "data:image/"):
+		return true
+	}
+	u, err := url.Parse(logo)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// currentBranding resolves the branding for this request: env, then the demo config,
+// then the defaults
+func currentBranding() portalBranding {
+	resolved := branding.sanitized()
+	if demoMode {
+		if config, err := demoConfig.current(); err == nil {
+			resolved = resolved.merged(config.portalBranding.sanitized())
+		}
+	}
+	return resolved.merged(defaultBranding)
+}
+
+// handlePortalConfig serves the portal branding. It only changes with the deployment
+// or the demo config, so clients revalidate it with its ETag.
+func handlePortalConfig(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != "GET" && r.Method != "HEAD" {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body bytes.Buffer
+	if err := writeJSON(&body, r, currentBranding()); err != nil {
+		log.Printf("ERROR encoding portal config: %v", err)
+		http.Error(w, `{"error":"failed to encode config"}`, http.StatusInternalServerError)
+		return
+	}
+
+	sum := sha256.Sum256(body.Bytes())
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	if r.Method == http.MethodHead {
+		return
+	}
+	w.Write(body.Bytes())
+}
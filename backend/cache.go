@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dedupeByTitle collapses apps sharing a title into one tile, set by DEDUPE=true
+var dedupeByTitle bool
+
+// cacheTTL is how long discovered apps are reused before re-discovering; zero disables caching
+var cacheTTL = 30 * time.Second
+
+var (
+	// lastGood holds the most recent successful discovery result
+	lastGood struct {
+		sync.RWMutex
+		apps        []App
+		fetchedAt   time.Time
+		invalidated bool
+		// err is the outcome of the most recent discovery attempt
+		err error
+	}
+
+	// refreshMu serializes discovery so concurrent requests on an expired cache
+	// share one List call instead of each firing their own
+	refreshMu sync.Mutex
+)
+
+// discoverApps returns the cached apps while they are younger than CACHE_TTL and
+// re-discovers them otherwise. When re-discovery fails but an earlier result exists,
+// that stale result is served instead of an error. In maintenance mode discovery is
+// frozen and the last good result is served.
+func discoverApps(ctx context.Context) ([]App, error) {
+	if maintenanceMode.Load() {
+		return lastGoodApps(), nil
+	}
+	if cacheFresh() {
+		return lastGoodApps(), nil
+	}
+
+	refreshMu.Lock()
+	defer refreshMu.Unlock()
+
+	// Another request may have refreshed the cache while we waited for the lock
+	if cacheFresh() {
+		return lastGoodApps(), nil
+	}
+
+	apps, err := loadApps(ctx)
+	if err != nil {
+		if fetchedAt := lastFetchedAt(); !fetchedAt.IsZero() {
+			log.Printf("WARNING: Serving apps cached at %s after discovery failure: %v", fetchedAt.Format(time.RFC3339), err)
+			return lastGoodApps(), nil
+		}
+		return nil, err
+	}
+	return apps, nil
+}
+
+// refreshApps re-discovers apps bypassing the cache and stores the result in it
+func refreshApps(ctx context.Context) ([]App, error) {
+	refreshMu.Lock()
+	defer refreshMu.Unlock()
+	return loadApps(ctx)
+}
+
+// loadApps loads all enabled apps from the demo config or the Kubernetes API and
+// stores them as the last good result; callers must hold refreshMu
+func loadApps(ctx context.Context) ([]App, error) {
+	var apps []App
+	var err error
+	if demoMode {
+		apps, err = getDemoApps()
+	} else {
+		apps, err = getK8sApps(ctx)
+	}
+	if err != nil {
+		// A client that went away says nothing about the cluster, so it doesn't count
+		// as a failed load
+		if ctx.Err() != context.Canceled {
+			lastGood.Lock()
+			lastGood.err = err
+			lastGood.Unlock()
+		}
+		return nil, err
+	}
+
+	if dedupeByTitle {
+		apps = dedupeApps(apps)
+	}
+	validateAppURLs(apps)
+	sortApps(apps)
+
+	lastGood.Lock()
+	lastGood.apps = apps
+	lastGood.fetchedAt = time.Now()
+	lastGood.invalidated = false
+	lastGood.err = nil
+	lastGood.Unlock()
+
+	return lastGoodApps(), nil
+}
+
+// dedupeApps collapses apps with the same case-insensitive title, e.g. internal and
+// external ingresses for one service. The merged tile keeps the first https URL and
+// the union of the groups and tags; if any duplicate is public the merged tile stays public.
+func dedupeApps(apps []App) []App {
+	index := make(map[string]int)
+	var deduped []App
+	for _, app := range apps {
+		key := strings.ToLower(strings.TrimSpace(app.Title))
+		i, ok := index[key]
+		if !ok {
+			if key != "" {
+				index[key] = len(deduped)
+			}
+			deduped = append(deduped, app)
+			continue
+		}
+
+		merged := &deduped[i]
+		log.Printf("Merging duplicate app %q: %s into %s", app.Title, app.URL, merged.URL)
+		if !strings.HasPrefix(merged.URL, "https://") && strings.HasPrefix(app.URL, "https://") {
+			merged.URL = app.URL
+		}
+		if len(app.Tags) > 0 {
+			merged.Tags = append([]string(nil), merged.Tags...)
+			for _, tag := range app.Tags {
+				if !hasGroup(merged.Tags, tag) {
+					merged.Tags = append(merged.Tags, tag)
+				}
+			}
+		}
+		if len(merged.Groups) == 0 || len(app.Groups) == 0 {
+			merged.Groups = nil
+			continue
+		}
+		// Copy first: apps from one ingress share their Groups slice
+		merged.Groups = append([]string(nil), merged.Groups...)
+		for _, group := range app.Groups {
+			if !hasGroup(merged.Groups, group) {
+				merged.Groups = append(merged.Groups, group)
+			}
+		}
+	}
+	return deduped
+}
+
+// sortApps orders apps by dashboard.home/weight ascending, with unweighted apps after
+// weighted ones, then by case-insensitive title so the portal doesn't reshuffle
+func sortApps(apps []App) {
+	sort.SliceStable(apps, func(i, j int) bool {
+		a, b := apps[i], apps[j]
+		if (a.Weight == nil) != (b.Weight == nil) {
+			return a.Weight != nil
+		}
+		if a.Weight != nil && *a.Weight != *b.Weight {
+			return *a.Weight < *b.Weight
+		}
+		return strings.ToLower(a.Title) < strings.ToLower(b.Title)
+	})
+}
+
+// cacheFresh reports whether the cached apps are younger than CACHE_TTL and still valid
+func cacheFresh() bool {
+	lastGood.RLock()
+	defer lastGood.RUnlock()
+	return cacheTTL > 0 && !lastGood.invalidated && !lastGood.fetchedAt.IsZero() && time.Since(lastGood.fetchedAt) < cacheTTL
+}
+
+// invalidateCache forces the next discovery to rebuild apps while keeping the last
+// good result available as a fallback
+func invalidateCache() {
+	lastGood.Lock()
+	lastGood.invalidated = true
+	lastGood.Unlock()
+}
+
+// lastFetchedAt returns when the cached apps were discovered, zero if never
+func lastFetchedAt() time.Time {
+	lastGood.RLock()
+	defer lastGood.RUnlock()
+	return lastGood.fetchedAt
+}
+
+// writeStaleWarning marks a response built from apps cached before the latest
+// discovery failure with a "110 Response is Stale" Warning header
+func writeStaleWarning(w http.ResponseWriter) {
+	fetchedAt := lastFetchedAt()
+	w.Header().Set("Warning", fmt.Sprintf(`110 - "Response is Stale" "%s"`, fetchedAt.UTC().Format(http.TimeFormat)))
+}
+
+// lastGoodApps returns a copy of the last successful discovery result
+func lastGoodApps() []App {
+	lastGood.RLock()
+	defer lastGood.RUnlock()
+	return append([]App(nil), lastGood.apps...)
+}
+
+// lastLoadError returns the error of the most recent discovery attempt, or nil if it succeeded
+func lastLoadError() error {
+	lastGood.RLock()
+	defer lastGood.RUnlock()
+	return lastGood.err
+}
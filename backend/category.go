@@ -0,0 +1,99 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"strings"
+)
+
+// AppCategory is one section of the ?grouped=true apps response
+type AppCategory struct {
+	Category string `json:"category"`
+	Apps     []App  `json:"apps"`
+}
+
+// parseCategorySources parses the CATEGORY_SOURCE list, dropping unknown entries
+func parseCategorySources(value string) []string {
+	var sources []string
+	for _, source := range strings.Split(value, ",") {
+		source = strings.ToLower(strings.TrimSpace(source))
+		switch source {
+		case "annotation", "namespace", "default":
+			sources = append(sources, source)
+		case "":
+		default:
+			log.Printf("WARNING: Ignoring unknown category source %q", source)
+		}
+	}
+	return sources
+}
+
+// resolveCategory returns the category from the first configured source that yields a non-empty value
+func resolveCategory(annotations appAnnotations, namespace string) string {
+	for _, source := range categorySources {
+		var category string
+		switch source {
+		case "annotation":
+			// Namespace defaults stand in for a missing annotation
+			category = annotations.get("category")
+			if category == "" {
+				category = namespaceDefaults.get(namespace).Category
+			}
+		case "namespace":
+			category = namespace
+		case "default":
+			category = defaultCategory
+		}
+		if category != "" {
+			return category
+		}
+	}
+	return ""
+}
+
+// appsInCategory keeps the apps in category, ignoring case; uncategorized apps belong
+// to DEFAULT_CATEGORY as in groupAppsByCategory
+func appsInCategory(apps []App, category string) []App {
+	matched := []App{}
+	for _, app := range apps {
+		appCategory := app.Category
+		if appCategory == "" {
+			appCategory = defaultCategory
+		}
+		if strings.EqualFold(appCategory, category) {
+			matched = append(matched, app)
+		}
+	}
+	return matched
+}
+
+// groupAppsByCategory buckets apps by category, keeping their order within each bucket.
+// Sections are sorted by name with the DEFAULT_CATEGORY bucket for uncategorized apps last.
+func groupAppsByCategory(apps []App) []AppCategory {
+	index := make(map[string]int)
+	groups := []AppCategory{}
+	for _, app := range apps {
+		category := app.Category
+		if category == "" {
+			category = defaultCategory
+		}
+		key := strings.ToLower(category)
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, AppCategory{Category: category})
+		}
+		groups[i].Apps = append(groups[i].Apps, app)
+	}
+
+	fallback := strings.ToLower(defaultCategory)
+	sort.SliceStable(groups, func(i, j int) bool {
+		a, b := strings.ToLower(groups[i].Category), strings.ToLower(groups[j].Category)
+		if (a == fallback) != (b == fallback) {
+			return b == fallback
+		}
+		return a < b
+	})
+	return groups
+}
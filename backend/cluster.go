@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// kubeCluster is one Kubernetes cluster apps are discovered in, with its lazily
+// created client and the informers watching it
+type kubeCluster struct {
+	// name tags the cluster's apps; it is empty for the default single cluster
+	name       string
+	kubeconfig string
+	context    string
+
+	mu             sync.Mutex
+	clientset      kubernetes.Interface
+	ingressVersion string
+
+	watchers clusterWatchers
+}
+
+// clusters are the clusters apps are aggregated from: the CLUSTERS list, or a single
+// default cluster configured by --kubeconfig, $KUBECONFIG or the service account
+var clusters = []*kubeCluster{{}}
+
+// String names the cluster in log messages
+func (c *kubeCluster) String() string {
+	if c.name == "" {
+		return "default"
+	}
+	return c.name
+}
+
+// parseClusters parses CLUSTERS, a comma-separated list of name=kubeconfig entries
+// with an optional :context suffix, e.g. "home=/kube/home,offsite=/kube/offsite:admin"
+func parseClusters(value string) ([]*kubeCluster, error) {
+	var parsed []*kubeCluster
+	seen := make(map[string]bool)
+	for _, entry := range parseListAnnotation(value) {
+		name, target, ok := strings.Cut(entry, "=")
+		name, target = strings.TrimSpace(name), strings.TrimSpace(target)
+		if !ok || name == "" || target == "" {
+			return nil, fmt.Errorf("CLUSTERS entry %q must be name=kubeconfig[:context]", entry)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("CLUSTERS lists cluster %q twice", name)
+		}
+		seen[name] = true
+
+		path, kubeContext, _ := strings.Cut(target, ":")
+		parsed = append(parsed, &kubeCluster{name: name, kubeconfig: path, context: kubeContext})
+	}
+	if len(parsed) == 0 {
+		return nil, errors.New("CLUSTERS is set but lists no clusters")
+	}
+	return parsed, nil
+}
+
+// getK8sApps queries every cluster in parallel and merges their apps. A failing
+// cluster is logged and skipped so the reachable ones still show; discovery only
+// fails when no cluster answers.
+func getK8sApps(ctx context.Context) ([]App, error) {
+	type result struct {
+		apps      []App
+		ingresses int
+		err       error
+	}
+	results := make([]result, len(clusters))
+
+	var wg sync.WaitGroup
+	for i, c := range clusters {
+		wg.Add(1)
+		go func(i int, c *kubeCluster) {
+			defer wg.Done()
+			apps, ingresses, err := c.apps(ctx)
+			results[i] = result{apps: apps, ingresses: ingresses, err: err}
+		}(i, c)
+	}
+	wg.Wait()
+
+	var apps []App
+	var errs []error
+	ingresses := 0
+	for i, r := range results {
+		if r.err != nil {
+			if len(clusters) > 1 {
+				log.Printf("WARNING: Skipping apps from cluster %s: %v", clusters[i], r.err)
+			}
+			errs = append(errs, r.err)
+			continue
+		}
+		apps = append(apps, r.apps...)
+		ingresses += r.ingresses
+	}
+	if len(errs) == len(clusters) {
+		if len(errs) == 1 {
+			return nil, errs[0]
+		}
+		return nil, fmt.Errorf("no cluster reachable: %w", errs[0])
+	}
+
+	discoveredIngresses.Set(float64(ingresses))
+	enabledAppsGauge.Set(float64(len(apps)))
+	log.Printf("Kubernetes mode: %d apps enabled", len(apps))
+	return apps, nil
+}
+
+// connectClusters creates every cluster's client and starts its informers. Failures
+// are logged by the client; the returned error counts them and wraps the first.
+func connectClusters(ctx context.Context) error {
+	var errs []error
+	for _, c := range clusters {
+		if _, _, err := c.client(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("cluster %s: %w", c, err))
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d of %d clusters failed, first: %w", len(errs), len(clusters), errs[0])
+}
+
+// clustersSynced reports whether at least one cluster's informer caches have synced
+func clustersSynced() bool {
+	for _, c := range clusters {
+		if c.watchers.synced.Load() {
+			return true
+		}
+	}
+	return false
+}
+
+// clustersFresh reports whether at least one cluster has synced and its informers are
+// not stale
+func clustersFresh() bool {
+	for _, c := range clusters {
+		if c.watchers.synced.Load() && !c.stale() {
+			return true
+		}
+	}
+	return false
+}
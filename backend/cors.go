@@ -0,0 +1,74 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// allowedOrigins lists the origins allowed to call the API cross-origin; "*" allows
+// any origin. Empty leaves CORS disabled.
+var allowedOrigins []string
+
+// corsOrigin returns the Access-Control-Allow-Origin value for origin and whether
+// credentials may be sent, or "" when the origin is not allowed. A wildcard never
+// allows credentials, even next to listed origins: browsers reject "*" with credentials,
+// and group-bearing cookies must only flow to origins named in ALLOWED_ORIGINS.
+func corsOrigin(origin string) (string, bool) {
+	if origin == "" {
+		return "", false
+	}
+	wildcard := false
+	for _, allowed := range allowedOrigins {
+		if allowed == "*" {
+			wildcard = true
+			continue
+		}
+		if strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return origin, true
+		}
+	}
+	if wildcard {
+		return "*", false
+	}
+	return "", false
+}
+
+// corsAllowedHeaders lists the request headers a preflight allows: Authorization for the
+// bearer token of AUTH_MODE=jwt, and the configured GROUPS_HEADER and USER_HEADER for
+// callers that forward them through the auth proxy.
+func corsAllowedHeaders() string {
+	return strings.Join([]string{"Authorization", "Content-Type", requestIDHeader, groupsHeaderName, userHeaderName}, ", ")
+}
+
+// withCORS adds CORS headers for allowed origins and answers their preflight requests
+func withCORS(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(allowedOrigins) == 0 {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		header := w.Header()
+		header.Add("Vary", "Origin")
+
+		allow, credentials := corsOrigin(r.Header.Get("Origin"))
+		if allow == "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+		header.Set("Access-Control-Allow-Origin", allow)
+		header.Set("Access-Control-Expose-Headers", requestIDHeader)
+		if credentials {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			header.Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+			header.Set("Access-Control-Allow-Headers", corsAllowedHeaders())
+			header.Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
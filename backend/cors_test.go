@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// preflight sends a CORS preflight for origin through withCORS
+func preflight(origin, requestHeaders string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodOptions, "/api/apps", nil)
+	r.Header.Set("Origin", origin)
+	r.Header.Set("Access-Control-Request-Method", "GET")
+	r.Header.Set("Access-Control-Request-Headers", requestHeaders)
+	w := httptest.NewRecorder()
+	withCORS(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})).ServeHTTP(w, r)
+	return w
+}
+
+func TestCORSPreflightAllowsAuthorization(t *testing.T) {
+	saved := allowedOrigins
+	allowedOrigins = []string{"https://portal.example.com"}
+	defer func() { allowedOrigins = saved }()
+
+	w := preflight("https://portal.example.com", "authorization")
+	allowed := strings.ToLower(w.Header().Get("Access-Control-Allow-Headers"))
+	if !strings.Contains(allowed, "authorization") {
+		t.Errorf("Access-Control-Allow-Headers = %q, want Authorization for AUTH_MODE=jwt", allowed)
+	}
+}
+
+func TestCORSPreflightAllowsIdentityHeaders(t *testing.T) {
+	savedOrigins, savedGroups, savedUser := allowedOrigins, groupsHeaderName, userHeaderName
+	allowedOrigins = []string{"https://portal.example.com"}
+	groupsHeaderName, userHeaderName = "X-Auth-Groups", "X-Auth-User"
+	defer func() { allowedOrigins, groupsHeaderName, userHeaderName = savedOrigins, savedGroups, savedUser }()
+
+	w := preflight("https://portal.example.com", "x-auth-groups, x-auth-user")
+	allowed := strings.ToLower(w.Header().Get("Access-Control-Allow-Headers"))
+	for _, name := range []string{"x-auth-groups", "x-auth-user"} {
+		if !strings.Contains(allowed, name) {
+			t.Errorf("Access-Control-Allow-Headers = %q, want %s", allowed, name)
+		}
+	}
+}
+
+func TestCORSCredentials(t *testing.T) {
+	tests := []struct {
+		name            string
+		origins         []string
+		origin          string
+		wantAllow       string
+		wantCredentials bool
+	}{
+		{"listed origin", []string{"https://portal.example.com"}, "https://portal.example.com", "https://portal.example.com", true},
+		{"listed origin with trailing slash", []string{"https://portal.example.com/"}, "https://portal.example.com", "https://portal.example.com", true},
+		{"wildcard", []string{"*"}, "https://other.example.com", "*", false},
+		{"wildcard next to listed origin", []string{"*", "https://portal.example.com"}, "https://other.example.com", "*", false},
+		{"listed origin next to wildcard", []string{"*", "https://portal.example.com"}, "https://portal.example.com", "https://portal.example.com", true},
+		{"unlisted origin", []string{"https://portal.example.com"}, "https://other.example.com", "", false},
+	}
+	saved := allowedOrigins
+	defer func() { allowedOrigins = saved }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allowedOrigins = tt.origins
+			w := preflight(tt.origin, "content-type")
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.wantAllow {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantAllow)
+			}
+			got := w.Header().Get("Access-Control-Allow-Credentials") == "true"
+			if got != tt.wantCredentials {
+				t.Errorf("Access-Control-Allow-Credentials sent = %v, want %v", got, tt.wantCredentials)
+			}
+		})
+	}
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"gopkg.in/yaml.v3"
+)
+
+// demoConfigPaths are tried in order for the demo mode config file; CONFIG_PATH
+// replaces them with a single path
+var demoConfigPaths = []string{"/etc/dashboard/config.yaml", "config.yaml"}
+
+// demoConfig caches the parsed demo config so requests don't touch the disk; load
+// swaps in a new copy atomically
+var demoConfig = &demoConfigStore{}
+
+type demoConfigStore struct {
+	mu     sync.RWMutex
+	config *Config
+	// path is the file the config was last read from
+	path string
+	// userGroups are the groups every demo request is treated as having
+	userGroups []string
+}
+
+// readDemoConfig reads and parses the first demo config file that exists
+func readDemoConfig() (*Config, string, error) {
+	path := demoConfigPaths[0]
+	data, err := os.ReadFile(path)
+	for _, fallback := range demoConfigPaths[1:] {
+		if err == nil {
+			break
+		}
+		path = fallback
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	var config Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, "", err
+	}
+	return &config, path, nil
+}
+
+// validateDemoConfig checks the demo config file, returning every problem found:
+// parse errors, enabled ingresses without a title and empty group entries
+func validateDemoConfig() []string {
+	config, path, err := readDemoConfig()
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	var problems []string
+	if config.Groups != "" && hasEmptyEntry(config.Groups) {
+		problems = append(problems, fmt.Sprintf("%s: groups %q contains an empty group", path, config.Groups))
+	}
+	for _, problem := range config.portalBranding.problems() {
+		problems = append(problems, fmt.Sprintf("%s: %s", path, problem))
+	}
+	for i, ing := range config.Ingresses {
+		annotations := annotationsFor(sourceIngress, ing.Namespace, ing.Annotations)
+		if !annotations.getBool("enabled") {
+			continue
+		}
+		if annotations.get("title") == "" {
+			problems = append(problems, fmt.Sprintf("%s: ingresses[%d] is enabled but has no %stitle", path, i, annotations.prefix))
+		}
+		for _, key := range []string{"groups", "deny-groups"} {
+			if raw := annotations.get(key); raw != "" && hasEmptyEntry(raw) {
+				problems = append(problems, fmt.Sprintf("%s: ingresses[%d] %s%s=%q contains an empty group", path, i, annotations.prefix, key, raw))
+			}
+		}
+	}
+	return problems
+}
+
+// hasEmptyEntry reports whether a comma-separated list has a blank entry, e.g. "a,,b"
+func hasEmptyEntry(list string) bool {
+	for _, entry := range strings.Split(list, ",") {
+		if strings.TrimSpace(entry) == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// load re-reads the demo config and swaps it in, leaving the previous one in place on error
+func (d *demoConfigStore) load() error {
+	config, path, err := readDemoConfig()
+	if err != nil {
+		return err
+	}
+
+	groups := parseListAnnotation(config.Groups)
+
+	d.mu.Lock()
+	d.config = config
+	d.path = path
+	d.userGroups = groups
+	d.mu.Unlock()
+
+	// NAMESPACE_DEFAULTS_FILE wins over the demo file
+	namespaceDefaults.setFromDemo(config.NamespaceDefaults)
+	log.Printf("Demo mode enabled with groups: %v (config %s)", groups, path)
+	return nil
+}
+
+// current returns the cached demo config, loading it if startup failed to
+func (d *demoConfigStore) current() (*Config, error) {
+	d.mu.RLock()
+	config := d.config
+	d.mu.RUnlock()
+	if config != nil {
+		return config, nil
+	}
+
+	if err := d.load(); err != nil {
+		return nil, err
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.config == nil {
+		return nil, errors.New("demo config not loaded")
+	}
+	return d.config, nil
+}
+
+// groups returns the demo user's groups
+func (d *demoConfigStore) groups() []string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.userGroups
+}
+
+// configPath returns the file the demo config was last read from
+func (d *demoConfigStore) configPath() string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.path
+}
+
+// reloadDemoConfig re-reads the demo config and refreshes the apps served from it
+func reloadDemoConfig(trigger string) {
+	if err := demoConfig.load(); err != nil {
+		log.Printf("ERROR reloading demo config after %s: %v", trigger, err)
+		return
+	}
+	invalidateCache()
+	appsUpdates.notify()
+
+	apps, err := getDemoApps()
+	if err != nil {
+		log.Printf("ERROR reloading demo config after %s: %v", trigger, err)
+		return
+	}
+	log.Printf("Demo config reloaded after %s: %d apps", trigger, len(apps))
+}
+
+// reloadDemoConfigOnSIGHUP reloads the demo config on every SIGHUP
+func reloadDemoConfigOnSIGHUP() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		log.Printf("SIGHUP received, reloading demo config")
+		reloadDemoConfig("SIGHUP")
+	}
+}
+
+// watchDemoConfig reloads the demo config whenever its file changes. The directory
+// is watched rather than the file: a mounted ConfigMap updates by swapping its
+// ..data symlink, which removes the watched inode, while the directory watch survives.
+func watchDemoConfig() {
+	path := demoConfig.configPath()
+	if path == "" {
+		log.Printf("WARNING: Not watching demo config: no config file loaded")
+		return
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Printf("WARNING: Not watching demo config: %v", err)
+		return
+	}
+	defer watcher.Close()
+
+	dir, file := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	if err := watcher.Add(dir); err != nil {
+		log.Printf("WARNING: Not watching demo config %s: %v", path, err)
+		return
+	}
+	log.Printf("Watching demo config %s for changes", path)
+
+	// Editors and ConfigMap swaps produce bursts of events, so reload once they settle
+	var debounce *time.Timer
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			name := filepath.Base(event.Name)
+			if name != file && name != "..data" {
+				continue
+			}
+			if debugMode {
+				log.Printf("DEBUG: Demo config event: %s", event)
+			}
+			if debounce != nil {
+				debounce.Stop()
+			}
+			debounce = time.AfterFunc(200*time.Millisecond, func() { reloadDemoConfig("file change") })
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("WARNING: Demo config watcher error: %v", err)
+		}
+	}
+}
+
+// demoBaseDomain is the domain demo apps without a dashboard.home/host are placed
+// under, set by DEMO_BASE_DOMAIN
+var demoBaseDomain = "example.com"
+
+// demoHost is a demo app's host: its dashboard.home/host, or its title slugified under
+// DEMO_BASE_DOMAIN, e.g. "Home Assistant" -> home-assistant.example.com
+func demoHost(host, title string) string {
+	if host != "" {
+		return host
+	}
+	if slug := slugify(title); slug != "" {
+		return slug + "." + demoBaseDomain
+	}
+	return demoBaseDomain
+}
+
+// slugify lowercases s and joins its runs of ASCII letters and digits with dashes
+func slugify(s string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+		dash = true
+	}
+	return b.String()
+}
+
+// getDemoApps builds apps from the cached demo config for development/testing
+func getDemoApps() ([]App, error) {
+	config, err := demoConfig.current()
+	if err != nil {
+		discoveryReports.record("demo", nil, err)
+		return nil, err
+	}
+
+	log.Printf("Demo mode: loading %d ingress configs from file", len(config.Ingresses))
+
+	var apps []App
+	var decisions []discoveryDecision
+	for _, ing := range config.Ingresses {
+		annotations := annotationsFor(sourceIngress, ing.Namespace, ing.Annotations)
+		decision := newDecision(sourceIngress, ing.Namespace, annotations.get("title"), annotations)
+		if reason := skipReason(annotations, nil, ing.Namespace, ""); reason != "" {
+			decisions = append(decisions, decision.skipped(reason))
+			continue
+		}
+
+		// Demo entries go through the same mapping as discovered objects, as one route
+		host := demoHost(annotations.get("host"), annotations.get("title"))
+		route := appRoute{host: host, url: "https://" + host}
+		found := appsFromRoutes(annotations, ing.Namespace, "", []appRoute{route})
+		decisions = append(decisions, decision.included(len(found)))
+		apps = append(apps, found...)
+	}
+	discoveryReports.record("demo", decisions, nil)
+
+	discoveredIngresses.Set(float64(len(config.Ingresses)))
+	enabledAppsGauge.Set(float64(len(apps)))
+	log.Printf("Demo mode: %d apps enabled", len(apps))
+	return apps, nil
+}
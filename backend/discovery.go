@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+)
+
+// Discovery sources selectable with DISCOVERY_SOURCES; each also names the
+// ANNOTATION_PREFIXES entry its annotations are read with
+const (
+	sourceIngress      = "ingress"
+	sourceHTTPRoute    = "httproute"
+	sourceIngressRoute = "ingressroute"
+)
+
+// discoverySources lists the resource kinds scanned for apps
+var discoverySources = []string{sourceIngress}
+
+// dynamicSources maps CRD-backed discovery sources to the resource they watch
+// through the dynamic client
+var dynamicSources = map[string]schema.GroupVersionResource{
+	sourceHTTPRoute:    {Group: "gateway.networking.k8s.io", Version: "v1", Resource: "httproutes"},
+	sourceIngressRoute: {Group: "traefik.io", Version: "v1alpha1", Resource: "ingressroutes"},
+}
+
+// parseDiscoverySources parses the DISCOVERY_SOURCES list, dropping unknown entries
+func parseDiscoverySources(value string) []string {
+	var sources []string
+	for _, source := range parseListAnnotation(value) {
+		source = strings.ToLower(source)
+		if _, ok := dynamicSources[source]; !ok && source != sourceIngress {
+			log.Printf("WARNING: Ignoring unknown discovery source %q", source)
+			continue
+		}
+		sources = append(sources, source)
+	}
+	return sources
+}
+
+// discoveryEnabled reports whether DISCOVERY_SOURCES includes source
+func discoveryEnabled(source string) bool {
+	for _, s := range discoverySources {
+		if s == source {
+			return true
+		}
+	}
+	return false
+}
+
+// servedDynamicSources returns the enabled CRD-backed sources whose resource the
+// cluster serves. A missing CRD only disables its source, so the informers can
+// still sync.
+func servedDynamicSources(ctx context.Context, client discovery.DiscoveryInterface) (map[string]schema.GroupVersionResource, error) {
+	served := make(map[string]schema.GroupVersionResource)
+	for source, gvr := range dynamicSources {
+		if !discoveryEnabled(source) {
+			continue
+		}
+
+		var resources []string
+		err := retryTransient(ctx, gvr.GroupVersion().String()+" discovery", func() error {
+			list, err := serverResourcesForGroupVersion(ctx, client, gvr.GroupVersion().String())
+			if err != nil {
+				return err
+			}
+			resources = resources[:0]
+			for _, resource := range list.APIResources {
+				resources = append(resources, resource.Name)
+			}
+			return nil
+		})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+
+		found := false
+		for _, name := range resources {
+			found = found || name == gvr.Resource
+		}
+		if !found {
+			log.Printf("WARNING: %s is not served by the cluster, skipping %s discovery", gvr.GroupResource(), source)
+			continue
+		}
+		served[source] = gvr
+	}
+	return served, nil
+}
+
+// cachedObjects returns every object in the informer stores of a CRD-backed source,
+// ordered by namespace and name
+func (c *kubeCluster) cachedObjects(source string) []*unstructured.Unstructured {
+	var objects []*unstructured.Unstructured
+	for _, informer := range c.watchers.dynamic[source] {
+		for _, obj := range informer.GetStore().List() {
+			if u, ok := obj.(*unstructured.Unstructured); ok {
+				objects = append(objects, u)
+			}
+		}
+	}
+	sort.Slice(objects, func(i, j int) bool {
+		if objects[i].GetNamespace() != objects[j].GetNamespace() {
+			return objects[i].GetNamespace() < objects[j].GetNamespace()
+		}
+		return objects[i].GetName() < objects[j].GetName()
+	})
+	return objects
+}
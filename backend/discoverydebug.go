@@ -0,0 +1,145 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// pprofEnabled is set by ENABLE_PPROF=true; it also opens /debug/discovery to everyone
+var pprofEnabled bool
+
+// discoveryDecision records whether one discovered object became an app and why
+type discoveryDecision struct {
+	Source    string `json:"source"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Included  bool   `json:"included"`
+	Reason    string `json:"reason"`
+	// Apps counts the apps an included object produced, one per host
+	Apps int `json:"apps,omitempty"`
+	// Annotations are the object's dashboard annotations, only with LOG_LEVEL=DEBUG
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// newDecision starts the decision for an object, capturing its annotations in debug mode
+func newDecision(source, namespace, name string, annotations appAnnotations) discoveryDecision {
+	decision := discoveryDecision{Source: source, Namespace: namespace, Name: name}
+	if debugMode {
+		for key, value := range annotations.values {
+			if strings.HasPrefix(key, annotations.prefix) {
+				if decision.Annotations == nil {
+					decision.Annotations = make(map[string]string)
+				}
+				decision.Annotations[key] = value
+			}
+		}
+	}
+	return decision
+}
+
+// skipped marks the object as not producing an app
+func (d discoveryDecision) skipped(reason string) discoveryDecision {
+	d.Reason = reason
+	return d
+}
+
+// included marks the object as producing apps; objects without a usable host produce none
+func (d discoveryDecision) included(apps int) discoveryDecision {
+	if apps == 0 {
+		d.Reason = "enabled but exposes no host"
+		return d
+	}
+	d.Included = true
+	d.Apps = apps
+	d.Reason = "included"
+	return d
+}
+
+// skipReason explains why an object's annotations keep it off the portal, or returns
+// "" when it is a portal app
+func skipReason(annotations appAnnotations, enabled enabledApps, namespace, name string) string {
+	if !annotations.getBool("enabled") && !enabled.contains(annotations.get("id"), namespace, name) {
+		return "not enabled: no " + annotations.prefix + `enabled: "true" annotation or enabled apps ConfigMap entry`
+	}
+	// hidden is a temporary override that also beats the enabled apps ConfigMap
+	if annotations.getBool("hidden") {
+		return "hidden by " + annotations.prefix + "hidden"
+	}
+	return ""
+}
+
+// discoveryReport is the outcome of the latest discovery run in one cluster
+type discoveryReport struct {
+	Cluster   string              `json:"cluster"`
+	CheckedAt time.Time           `json:"checkedAt"`
+	Error     string              `json:"error,omitempty"`
+	Decisions []discoveryDecision `json:"decisions"`
+}
+
+// discoveryReports keeps the latest report per cluster for /debug/discovery
+var discoveryReports = &discoveryReportStore{reports: make(map[string]discoveryReport)}
+
+type discoveryReportStore struct {
+	mu      sync.Mutex
+	reports map[string]discoveryReport
+}
+
+// record replaces the cluster's report; a failed run keeps no decisions
+func (s *discoveryReportStore) record(cluster string, decisions []discoveryDecision, err error) {
+	report := discoveryReport{Cluster: cluster, CheckedAt: time.Now(), Decisions: decisions}
+	if report.Decisions == nil {
+		report.Decisions = []discoveryDecision{}
+	}
+	if err != nil {
+		report.Error = err.Error()
+	}
+
+	s.mu.Lock()
+	s.reports[cluster] = report
+	s.mu.Unlock()
+}
+
+// all returns the reports ordered by cluster name
+func (s *discoveryReportStore) all() []discoveryReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reports := make([]discoveryReport, 0, len(s.reports))
+	for _, report := range s.reports {
+		reports = append(reports, report)
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Cluster < reports[j].Cluster })
+	return reports
+}
+
+// handleDiscoveryDebug reports, per discovered object, whether it became an app and
+// why not. It is open to ADMIN_GROUPS members, or to everyone with ENABLE_PPROF=true.
+func handleDiscoveryDebug(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != "GET" {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	if !pprofEnabled && !isAdmin(getUserGroups(r)) {
+		http.Error(w, `{"error":"forbidden"}`, http.StatusForbidden)
+		return
+	}
+
+	// Populate the reports if nothing has run discovery yet; failures land in them too
+	if _, err := discoverApps(r.Context()); err != nil {
+		log.Printf("WARNING: Discovery for /debug/discovery failed request_id=%s: %v", requestID(r.Context()), err)
+	}
+
+	response := struct {
+		Clusters  []discoveryReport `json:"clusters"`
+		LastError string            `json:"lastError,omitempty"`
+	}{Clusters: discoveryReports.all()}
+	if err := lastLoadError(); err != nil {
+		response.LastError = err.Error()
+	}
+	writeJSON(w, r, response)
+}
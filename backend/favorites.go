@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// maxFavorites caps how many app IDs one user can pin
+const maxFavorites = 200
+
+// favoritesStore persists each user's favorite app IDs
+type favoritesStore interface {
+	// Get returns the user's favorite app IDs, empty when they have none
+	Get(user string) ([]string, error)
+	// Set replaces the user's favorite app IDs
+	Set(user string, ids []string) error
+}
+
+// favorites is the store selected by FAVORITES_STORE; nil disables the feature
+var favorites favoritesStore
+
+// newFavoritesStore builds the store named by FAVORITES_STORE; "file" keeps every
+// user's favorites in one JSON file at FAVORITES_FILE
+func newFavoritesStore(kind, path string) (favoritesStore, error) {
+	switch kind {
+	case "file":
+		return newFileFavoritesStore(path)
+	default:
+		return nil, fmt.Errorf("unknown FAVORITES_STORE %q, supported: file", kind)
+	}
+}
+
+// fileFavoritesStore keeps favorites in memory and rewrites the whole file on each change
+type fileFavoritesStore struct {
+	path string
+	mu   sync.Mutex
+	data map[string][]string
+}
+
+// newFileFavoritesStore loads path, starting empty when it does not exist yet
+func newFileFavoritesStore(path string) (*fileFavoritesStore, error) {
+	s := &fileFavoritesStore{path: path, data: make(map[string][]string)}
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, &s.data); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return s, nil
+}
+
+func (s *fileFavoritesStore) Get(user string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.data[user]...), nil
+}
+
+func (s *fileFavoritesStore) Set(user string, ids []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous, had := s.data[user]
+	if len(ids) == 0 {
+		delete(s.data, user)
+	} else {
+		s.data[user] = ids
+	}
+	if err := s.save(); err != nil {
+		if had {
+			s.data[user] = previous
+		} else {
+			delete(s.data, user)
+		}
+		return err
+	}
+	return nil
+}
+
+// save writes the file through a temporary file and a rename so a crash never leaves
+// it half written; callers must hold s.mu
+func (s *fileFavoritesStore) save() error {
+	raw, err := json.Marshal(s.data)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".favorites-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// favoritesResponse is the body of GET and POST /api/favorites
+type favoritesResponse struct {
+	Favorites []string `json:"favorites"`
+}
+
+// handleFavorites returns (GET) or replaces (POST) the calling user's favorite app IDs.
+// The user comes from USER_HEADER; without a store or a user the feature is off.
+func handleFavorites(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if favorites == nil {
+		http.Error(w, `{"error":"favorites disabled"}`, http.StatusNotFound)
+		return
+	}
+	user := getUserName(r)
+	if user == "" {
+		http.Error(w, `{"error":"favorites need a signed-in user"}`, http.StatusForbidden)
+		return
+	}
+	reqID := requestID(r.Context())
+
+	var ids []string
+	switch r.Method {
+	case http.MethodGet:
+		var err error
+		if ids, err = favorites.Get(user); err != nil {
+			log.Printf("ERROR reading favorites request_id=%s user=%s: %v", reqID, user, err)
+			http.Error(w, `{"error":"failed to read favorites"}`, http.StatusInternalServerError)
+			return
+		}
+	case http.MethodPost:
+		var body favoritesResponse
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+			return
+		}
+		ids = uniqueFavorites(body.Favorites)
+		if len(ids) > maxFavorites {
+			http.Error(w, fmt.Sprintf(`{"error":"at most %d favorites"}`, maxFavorites), http.StatusBadRequest)
+			return
+		}
+		if err := favorites.Set(user, ids); err != nil {
+			log.Printf("ERROR saving favorites request_id=%s user=%s: %v", reqID, user, err)
+			http.Error(w, `{"error":"failed to save favorites"}`, http.StatusInternalServerError)
+			return
+		}
+		log.Printf("Favorites updated request_id=%s user=%s count=%d", reqID, user, len(ids))
+	default:
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, r, favoritesResponse{Favorites: ids})
+}
+
+// uniqueFavorites drops empty and repeated IDs, keeping the caller's order
+func uniqueFavorites(ids []string) []string {
+	unique := []string{}
+	seen := make(map[string]bool)
+	for _, id := range ids {
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		unique = append(unique, id)
+	}
+	return unique
+}
+
+// markFavorites returns a copy of apps with Favorite set on the user's favorites; the
+// input may be the shared cache, so it is never modified
+func markFavorites(apps []App, user string) []App {
+	if favorites == nil || user == "" {
+		return apps
+	}
+	ids, err := favorites.Get(user)
+	if err != nil {
+		log.Printf("WARNING: Failed to read favorites for user=%s: %v", user, err)
+		return apps
+	}
+	if len(ids) == 0 {
+		return apps
+	}
+	favorite := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		favorite[id] = true
+	}
+
+	marked := make([]App, len(apps))
+	for i, app := range apps {
+		app.Favorite = favorite[app.ID]
+		marked[i] = app
+	}
+	return marked
+}
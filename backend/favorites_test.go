@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// memoryFavoritesStore is a favoritesStore backed by a map
+type memoryFavoritesStore map[string][]string
+
+func (s memoryFavoritesStore) Get(user string) ([]string, error) { return s[user], nil }
+
+func (s memoryFavoritesStore) Set(user string, ids []string) error {
+	s[user] = ids
+	return nil
+}
+
+// useFavorites enables favorites with the given per-user IDs for the rest of the test
+func useFavorites(t *testing.T, store memoryFavoritesStore) {
+	t.Helper()
+	saved := favorites
+	favorites = store
+	t.Cleanup(func() { favorites = saved })
+}
+
+// favoritesRequest builds a request from user, who is in the admin group
+func favoritesRequest(method, target, user string) *http.Request {
+	r := httptest.NewRequest(method, target, nil)
+	r.Header.Set(userHeaderName, user)
+	r.Header.Set(groupsHeaderName, "admin")
+	return r
+}
+
+func TestHandleAppByIDMarksFavorite(t *testing.T) {
+	seedApps(t, []App{
+		{ID: "grafana", Title: "Grafana", Groups: []string{"admin"}},
+		{ID: "argocd", Title: "ArgoCD", Groups: []string{"admin"}},
+	})
+	useFavorites(t, memoryFavoritesStore{"alice": {"grafana"}})
+
+	tests := []struct {
+		id, user string
+		want     bool
+	}{
+		{"grafana", "alice", true},
+		{"argocd", "alice", false},
+		{"grafana", "bob", false},
+		{"grafana", "", false},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		handleAppByID(w, favoritesRequest(http.MethodGet, "/api/apps/"+tt.id, tt.user))
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET /api/apps/%s as %q: status %d, body %s", tt.id, tt.user, w.Code, w.Body)
+		}
+		var app App
+		if err := json.Unmarshal(w.Body.Bytes(), &app); err != nil {
+			t.Fatal(err)
+		}
+		if app.Favorite != tt.want {
+			t.Errorf("GET /api/apps/%s as %q: favorite = %v, want %v", tt.id, tt.user, app.Favorite, tt.want)
+		}
+	}
+}
+
+func TestAppsStreamMarksFavorites(t *testing.T) {
+	seedApps(t, []App{
+		{ID: "grafana", Title: "Grafana", Groups: []string{"admin"}},
+		{ID: "argocd", Title: "ArgoCD", Groups: []string{"admin"}},
+	})
+	useFavorites(t, memoryFavoritesStore{"alice": {"argocd"}})
+
+	server := httptest.NewServer(http.HandlerFunc(handleAppsStream))
+	defer server.Close()
+
+	r := favoritesRequest(http.MethodGet, server.URL, "alice")
+	r.RequestURI = ""
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var apps []App
+		if err := json.Unmarshal([]byte(data), &apps); err != nil {
+			t.Fatal(err)
+		}
+		for _, app := range apps {
+			if want := app.ID == "argocd"; app.Favorite != want {
+				t.Errorf("streamed app %s: favorite = %v, want %v", app.ID, app.Favorite, want)
+			}
+		}
+		return
+	}
+	t.Fatalf("stream ended without an apps event: %v", scanner.Err())
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// groupMappingCheckInterval limits how often the mapping file is checked for changes
+const groupMappingCheckInterval = 10 * time.Second
+
+// groupHierarchy expands user groups with the parents implied by GROUP_MAPPING_FILE
+var groupHierarchy = &groupMapping{}
+
+// groupMapping holds a group -> implied groups mapping loaded from a YAML file, e.g.
+//
+//	admins: [users]
+//	users: [guests]
+//
+// so a member of admins is also treated as a member of users and guests.
+type groupMapping struct {
+	path string
+
+	mu        sync.RWMutex
+	implies   map[string][]string
+	modTime   time.Time
+	checkedAt time.Time
+}
+
+// load reads and parses the mapping file, replacing the cached mapping on success
+func (m *groupMapping) load() error {
+	info, err := os.Stat(m.path)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(m.path)
+	if err != nil {
+		return err
+	}
+
+	var raw map[string][]string
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	implies := make(map[string][]string, len(raw))
+	for group, parents := range raw {
+		key := strings.ToLower(strings.TrimSpace(group))
+		for _, parent := range parents {
+			if parent = strings.TrimSpace(parent); parent != "" {
+				implies[key] = append(implies[key], parent)
+			}
+		}
+	}
+
+	m.mu.Lock()
+	m.implies = implies
+	m.modTime = info.ModTime()
+	m.checkedAt = time.Now()
+	m.mu.Unlock()
+
+	log.Printf("Loaded group mapping from %s (%d groups)", m.path, len(implies))
+	return nil
+}
+
+// reloadIfChanged reloads the mapping when the file changed since the last load
+func (m *groupMapping) reloadIfChanged() {
+	m.mu.Lock()
+	if time.Since(m.checkedAt) < groupMappingCheckInterval {
+		m.mu.Unlock()
+		return
+	}
+	m.checkedAt = time.Now()
+	modTime := m.modTime
+	m.mu.Unlock()
+
+	info, err := os.Stat(m.path)
+	if err != nil {
+		log.Printf("WARNING: Failed to stat group mapping %s: %v", m.path, err)
+		return
+	}
+	if info.ModTime().Equal(modTime) {
+		return
+	}
+
+	if err := m.load(); err != nil {
+		// Keep serving the previous mapping rather than dropping implied groups
+		log.Printf("WARNING: Failed to reload group mapping: %v", err)
+	}
+}
+
+// expand returns the user's groups plus every group they transitively imply.
+// Cycles in the mapping are harmless since each group is visited once.
+func (m *groupMapping) expand(groups []string) []string {
+	if m.path == "" || len(groups) == 0 {
+		return groups
+	}
+	m.reloadIfChanged()
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	seen := make(map[string]bool, len(groups))
+	expanded := make([]string, 0, len(groups))
+	queue := append([]string(nil), groups...)
+
+	for len(queue) > 0 {
+		group := strings.TrimSpace(queue[0])
+		queue = queue[1:]
+
+		key := strings.ToLower(group)
+		if group == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		expanded = append(expanded, group)
+		queue = append(queue, m.implies[key]...)
+	}
+
+	if debugMode && len(expanded) != len(groups) {
+		log.Printf("DEBUG: Expanded groups %v to %v", groups, expanded)
+	}
+	return expanded
+}
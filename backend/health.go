@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Health check states reported per app
+const (
+	healthUp      = "up"
+	healthDown    = "down"
+	healthUnknown = "unknown"
+)
+
+// healthChecks holds the latest health result per checked URL
+var healthChecks = newHealthChecker()
+
+// healthCheckTimeout bounds a single probe; it defaults to FETCH_TIMEOUT
+var healthCheckTimeout time.Duration
+
+// Circuit breaker settings: after circuitFailureThreshold consecutive failed probes an
+// app is reported down without being probed until circuitCooldown has passed
+var (
+	circuitFailureThreshold = 3
+	circuitCooldown         = 5 * time.Minute
+)
+
+// Circuit breaker states reported per app
+const (
+	circuitClosed   = "closed"
+	circuitOpen     = "open"
+	circuitHalfOpen = "half-open"
+)
+
+// appHealth is the outcome of the most recent probe of an app
+type appHealth struct {
+	Status      string
+	LastChecked time.Time
+	Duration    time.Duration
+	// Failures counts consecutive failed probes; OpenUntil is when an open circuit
+	// lets the next probe through
+	Failures  int
+	OpenUntil time.Time
+	// CertExpiry is the NotAfter of the certificate an https app presented
+	CertExpiry time.Time
+}
+
+// circuit reports the breaker state at now: open while cooling down, half-open when
+// the next probe decides whether it closes again
+func (r appHealth) circuit(now time.Time) string {
+	switch {
+	case r.Failures < circuitFailureThreshold:
+		return circuitClosed
+	case now.Before(r.OpenUntil):
+		return circuitOpen
+	default:
+		return circuitHalfOpen
+	}
+}
+
+// healthChecker periodically probes every discovered app
+type healthChecker struct {
+	mu      sync.RWMutex
+	results map[string]appHealth
+	client  *http.Client
+	// enabled is set once checks run, so apps report "unknown" rather than nothing
+	enabled atomic.Bool
+}
+
+func newHealthChecker() *healthChecker {
+	return &healthChecker{
+		results: make(map[string]appHealth),
+		client: &http.Client{
+			Transport: http.DefaultTransport.(*http.Transport).Clone(),
+			// A redirect (e.g. to an SSO login) already proves the app is answering
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// apply copies the latest health results onto apps
+func (h *healthChecker) apply(apps []App) {
+	if !h.enabled.Load() {
+		return
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	now := time.Now()
+	for i := range apps {
+		result, ok := h.results[healthTarget(apps[i])]
+		if !ok || result.Status == healthUnknown {
+			apps[i].Status = healthUnknown
+			continue
+		}
+		checked := result.LastChecked
+		apps[i].Status = result.Status
+		apps[i].LastChecked = &checked
+		apps[i].Circuit = result.circuit(now)
+		if !result.CertExpiry.IsZero() {
+			expiry := result.CertExpiry
+			apps[i].CertExpiry = &expiry
+		}
+	}
+}
+
+// healthTarget is the URL probed for an app: its dashboard.home/healthcheck-path
+// resolved against the app URL, or the app URL itself
+func healthTarget(app App) string {
+	if app.HealthCheckPath == "" || app.URL == "" {
+		return app.URL
+	}
+	base, err := url.Parse(app.URL)
+	if err != nil {
+		return app.URL
+	}
+	ref, err := url.Parse(app.HealthCheckPath)
+	if err != nil {
+		log.Printf("WARNING: Ignoring invalid health check path %q for %s: %v", app.HealthCheckPath, app.Title, err)
+		return app.URL
+	}
+	return base.ResolveReference(ref).String()
+}
+
+// run probes every app on each tick until the process exits
+func (h *healthChecker) run(interval time.Duration) {
+	h.enabled.Store(true)
+	h.refresh()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		h.refresh()
+	}
+}
+
+// refresh probes all apps concurrently and drops results for apps that disappeared.
+// Apps whose circuit is open keep their last result instead of being probed.
+func (h *healthChecker) refresh() {
+	apps, err := discoverApps(context.Background())
+	if err != nil {
+		log.Printf("ERROR discovering apps for health checks: %v", err)
+		return
+	}
+
+	h.mu.RLock()
+	previous := h.results
+	h.mu.RUnlock()
+
+	now := time.Now()
+	// results is shared with the pool workers, so every write holds mu; the loop
+	// dedupes targets in its own seen set
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	results := make(map[string]appHealth)
+	seen := make(map[string]bool)
+
+	for _, app := range apps {
+		target := healthTarget(app)
+		if target == "" || seen[target] {
+			continue
+		}
+		seen[target] = true
+		last := previous[target]
+		if last.circuit(now) == circuitOpen {
+			mu.Lock()
+			results[target] = last
+			mu.Unlock()
+			continue
+		}
+
+		wg.Add(1)
+		backgroundFetches.submit("health", func() {
+			defer wg.Done()
+			result := tripCircuit(target, last, h.check(target))
+			mu.Lock()
+			results[target] = result
+			mu.Unlock()
+		})
+	}
+	wg.Wait()
+
+	h.mu.Lock()
+	h.results = results
+	h.mu.Unlock()
+
+	recordAppHealth(apps, results)
+
+	if debugMode {
+		log.Printf("DEBUG: Health checked %d apps", len(results))
+	}
+}
+
+// skipTLSVerify makes the health checker accept any certificate, for apps with
+// self-signed ones; it never applies to the Kubernetes client
+func (h *healthChecker) skipTLSVerify() {
+	transport := h.client.Transport.(*http.Transport)
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+	transport.TLSClientConfig.InsecureSkipVerify = true
+}
+
+// tripCircuit carries the failure count from the previous result into a new probe
+// result, opening the circuit once circuitFailureThreshold failures in a row are reached
+func tripCircuit(target string, last, result appHealth) appHealth {
+	if result.Status == healthUp {
+		if last.Failures >= circuitFailureThreshold {
+			log.Printf("Health check circuit closed: url=%s", target)
+		}
+		return result
+	}
+
+	result.Failures = last.Failures + 1
+	if result.Failures >= circuitFailureThreshold {
+		result.OpenUntil = result.LastChecked.Add(circuitCooldown)
+		if result.Failures == circuitFailureThreshold {
+			log.Printf("WARNING: Health check circuit opened after %d failures, next probe in %s: url=%s", result.Failures, circuitCooldown, target)
+		}
+	}
+	return result
+}
+
+// check performs a single GET against url; any response below 500 counts as up
+func (h *healthChecker) check(url string) appHealth {
+	ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	result := appHealth{Status: healthDown, LastChecked: start}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		log.Printf("WARNING: Health check request failed: url=%s err=%v", url, err)
+		return result
+	}
+
+	resp, err := h.client.Do(req)
+	result.Duration = time.Since(start)
+	if err != nil {
+		if debugMode {
+			log.Printf("DEBUG: Health check failed: url=%s err=%v", url, err)
+		}
+		return result
+	}
+	resp.Body.Close()
+
+	if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+		result.CertExpiry = resp.TLS.PeerCertificates[0].NotAfter
+	}
+	if resp.StatusCode < http.StatusInternalServerError {
+		result.Status = healthUp
+	}
+	return result
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// useFetchPool swaps in a fresh background pool with the given number of workers
+func useFetchPool(t *testing.T, workers int) {
+	t.Helper()
+	saved := backgroundFetches
+	backgroundFetches = &fetchPool{jobs: make(chan fetchJob)}
+	backgroundFetches.start(workers)
+	pool := backgroundFetches
+	t.Cleanup(func() {
+		close(pool.jobs)
+		backgroundFetches = saved
+	})
+}
+
+// TestHealthRefreshParallel probes many apps on several workers, with duplicate
+// targets and open circuits mixed in; run it with -race
+func TestHealthRefreshParallel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/down" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+	useFetchPool(t, 8)
+	savedTimeout := healthCheckTimeout
+	healthCheckTimeout = fetchTimeout
+	defer func() { healthCheckTimeout = savedTimeout }()
+
+	var apps []App
+	for i := 0; i < 50; i++ {
+		apps = append(apps, App{ID: fmt.Sprint("app", i), Title: fmt.Sprint("App ", i), URL: fmt.Sprintf("%s/app/%d", server.URL, i)})
+	}
+	// Duplicate targets are probed once
+	apps = append(apps, App{ID: "dup", Title: "Dup", URL: server.URL + "/app/0"})
+	apps = append(apps, App{ID: "down", Title: "Down", URL: server.URL + "/down"})
+	for i := 0; i < 10; i++ {
+		apps = append(apps, App{ID: fmt.Sprint("open", i), Title: fmt.Sprint("Open ", i), URL: fmt.Sprintf("%s/open/%d", server.URL, i)})
+	}
+	seedApps(t, apps)
+
+	h := newHealthChecker()
+	now := time.Now()
+	for i := 0; i < 10; i++ {
+		h.results[fmt.Sprintf("%s/open/%d", server.URL, i)] = appHealth{Status: healthDown, LastChecked: now, Failures: circuitFailureThreshold, OpenUntil: now.Add(time.Hour)}
+	}
+
+	for round := 0; round < 3; round++ {
+		h.refresh()
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if len(h.results) != 61 {
+		t.Errorf("got %d results, want 61", len(h.results))
+	}
+	for i := 0; i < 50; i++ {
+		if got := h.results[fmt.Sprintf("%s/app/%d", server.URL, i)].Status; got != healthUp {
+			t.Errorf("app %d status = %q, want %q", i, got, healthUp)
+		}
+	}
+	if got := h.results[server.URL+"/down"]; got.Status != healthDown || got.Failures != 3 {
+		t.Errorf("down app = %+v, want down after 3 failures", got)
+	}
+	for i := 0; i < 10; i++ {
+		if got := h.results[fmt.Sprintf("%s/open/%d", server.URL, i)]; got.LastChecked != now {
+			t.Errorf("open circuit %d was probed: %+v", i, got)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// cachedHTTPRoutes returns every Gateway API HTTPRoute in the informer stores
+func (c *kubeCluster) cachedHTTPRoutes() []*unstructured.Unstructured {
+	return c.cachedObjects(sourceHTTPRoute)
+}
+
+// httpRouteHosts returns one route per distinct hostname of an HTTPRoute. Wildcard
+// hostnames aren't clickable and are skipped. Links use https since the listener
+// protocol lives on the parent Gateway; dashboard.home/url overrides the result.
+func httpRouteHosts(route *unstructured.Unstructured) []appRoute {
+	hostnames, _, _ := unstructured.NestedStringSlice(route.Object, "spec", "hostnames")
+	path := cleanRoutePath(httpRoutePath(route))
+
+	var routes []appRoute
+	seen := make(map[string]bool)
+	for _, host := range hostnames {
+		key := strings.ToLower(host)
+		if host == "" || strings.HasPrefix(host, "*") || seen[key] {
+			continue
+		}
+		seen[key] = true
+		routes = append(routes, appRoute{host: host, url: "https://" + host + path})
+	}
+	return routes
+}
+
+// httpRoutePath returns the path of the route's first match, if any
+func httpRoutePath(route *unstructured.Unstructured) string {
+	rules, _, _ := unstructured.NestedSlice(route.Object, "spec", "rules")
+	if len(rules) == 0 {
+		return ""
+	}
+	rule, ok := rules[0].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	matches, _, _ := unstructured.NestedSlice(rule, "matches")
+	if len(matches) == 0 {
+		return ""
+	}
+	match, ok := matches[0].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	path, _, _ := unstructured.NestedString(match, "path", "value")
+	return path
+}
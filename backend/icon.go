@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// defaultIcon is used for apps without an icon annotation or namespace default icon;
+// when empty, an icon is generated from the title's first letter
+var defaultIcon string
+
+// resolveIcon picks the icon for an app: its annotation, then the namespace default,
+// then DEFAULT_ICON, then a letter icon derived from the title
+func resolveIcon(icon, title, namespace string) string {
+	if icon != "" {
+		return icon
+	}
+	if icon = namespaceDefaults.get(namespace).Icon; icon != "" {
+		return icon
+	}
+	if defaultIcon != "" {
+		return defaultIcon
+	}
+	return letterIcon(title)
+}
+
+// letterIcon renders the first letter of title as an SVG data URI, or "" for an empty title
+func letterIcon(title string) string {
+	r, _ := utf8.DecodeRuneInString(strings.TrimSpace(title))
+	if r == utf8.RuneError {
+		return ""
+	}
+	letter := html.EscapeString(string(unicode.ToUpper(r)))
+
+	svg := fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 64 64">`+
+		`<rect width="64" height="64" rx="12" fill="#64748b"/>`+
+		`<text x="32" y="43" font-family="sans-serif" font-size="32" font-weight="bold" fill="#fff" text-anchor="middle">%s</text>`+
+		`</svg>`, letter)
+	return "data:image/svg+xml," + url.PathEscape(svg)
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// icons proxies external app icons through /api/icon when ICON_PROXY=true, so
+// browsers never contact the icon hosts directly
+var icons = newIconProxy()
+
+var (
+	// iconCacheTTL is how long a fetched icon is served before it is fetched again
+	iconCacheTTL = time.Hour
+
+	// iconCacheSize caps the number of icons held in memory
+	iconCacheSize = 256
+
+	// iconFailureTTL is how long a failed fetch is remembered before the host is tried
+	// again, so requests for a dead icon host don't each trigger an outbound fetch
+	iconFailureTTL = time.Minute
+)
+
+// maxIconBytes bounds the size of a proxied icon
+const maxIconBytes = 1 << 20
+
+// placeholderIcon is served when an icon cannot be fetched and no earlier copy exists
+const placeholderIcon = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 64 64">` +
+	`<rect width="64" height="64" rx="12" fill="#64748b"/>` +
+	`<rect x="18" y="18" width="28" height="28" rx="4" fill="none" stroke="#fff" stroke-width="4"/>` +
+	`</svg>`
+
+// cachedIcon is one fetched icon and when it must be fetched again
+type cachedIcon struct {
+	data        []byte
+	contentType string
+	expiresAt   time.Time
+	// placeholder marks the stand-in served after a failed first fetch
+	placeholder bool
+}
+
+// iconProxy maps the hashes handed out in app icons to their remote URLs and caches
+// the fetched images. Only URLs that discovery registered can be fetched, so the
+// endpoint can't be used to make the portal request arbitrary URLs.
+type iconProxy struct {
+	mu      sync.Mutex
+	urls    map[string]string
+	cache   map[string]cachedIcon
+	client  *http.Client
+	enabled atomic.Bool
+	// fetches collapses concurrent misses for one icon into a single outbound fetch
+	fetches singleflight.Group
+}
+
+func newIconProxy() *iconProxy {
+	return &iconProxy{
+		urls:   make(map[string]string),
+		cache:  make(map[string]cachedIcon),
+		client: &http.Client{},
+	}
+}
+
+// iconKey identifies a remote icon by the hash of its URL
+func iconKey(iconURL string) string {
+	sum := sha256.Sum256([]byte(iconURL))
+	return hex.EncodeToString(sum[:16])
+}
+
+// apply points every external http(s) icon at the proxy. apps is the full discovered
+// list, so the registered URLs are replaced by its icons and icons of apps that are
+// gone are dropped from the cache.
+func (p *iconProxy) apply(apps []App) {
+	if !p.enabled.Load() {
+		return
+	}
+
+	urls := make(map[string]string)
+	for i := range apps {
+		icon := apps[i].Icon
+		if !strings.HasPrefix(icon, "http://") && !strings.HasPrefix(icon, "https://") {
+			continue
+		}
+		key := iconKey(icon)
+		urls[key] = icon
+		apps[i].Icon = "/api/icon?h=" + key
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.urls = urls
+	for key := range p.cache {
+		if _, ok := urls[key]; !ok {
+			delete(p.cache, key)
+		}
+	}
+}
+
+// get returns the icon for key from the cache, fetching it when missing or expired.
+// A failed fetch keeps serving the previous copy, or a placeholder, and is not retried
+// for ICON_FAILURE_TTL; concurrent fetches of one icon share a single request.
+func (p *iconProxy) get(key string) (cachedIcon, bool) {
+	p.mu.Lock()
+	iconURL, known := p.urls[key]
+	cached, hit := p.cache[key]
+	p.mu.Unlock()
+
+	if !known {
+		return cachedIcon{}, false
+	}
+	if hit && time.Now().Before(cached.expiresAt) {
+		return cached, true
+	}
+
+	result, _, _ := p.fetches.Do(key, func() (interface{}, error) {
+		fetched, err := p.fetch(iconURL)
+		if err != nil {
+			log.Printf("WARNING: Failed to fetch icon %s: %v", iconURL, err)
+			fetched = cachedIcon{data: []byte(placeholderIcon), contentType: "image/svg+xml", placeholder: true}
+			if hit {
+				fetched = cached
+			}
+			fetched.expiresAt = time.Now().Add(iconFailureTTL)
+		}
+
+		p.mu.Lock()
+		// The URL may have been dropped by apply while fetching
+		if _, ok := p.urls[key]; ok {
+			p.store(key, fetched)
+		}
+		p.mu.Unlock()
+		return fetched, nil
+	})
+	return result.(cachedIcon), true
+}
+
+// store caches an icon, evicting the one expiring first once ICON_CACHE_SIZE is
+// reached; callers must hold p.mu
+func (p *iconProxy) store(key string, icon cachedIcon) {
+	if _, ok := p.cache[key]; !ok && len(p.cache) >= iconCacheSize {
+		oldest := ""
+		for k, v := range p.cache {
+			if oldest == "" || v.expiresAt.Before(p.cache[oldest].expiresAt) {
+				oldest = k
+			}
+		}
+		delete(p.cache, oldest)
+	}
+	p.cache[key] = icon
+}
+
+// fetch downloads an icon, refusing responses that are not images
+func (p *iconProxy) fetch(iconURL string) (cachedIcon, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, iconURL, nil)
+	if err != nil {
+		return cachedIcon{}, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return cachedIcon{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return cachedIcon{}, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxIconBytes+1))
+	if err != nil {
+		return cachedIcon{}, err
+	}
+	if len(data) > maxIconBytes {
+		return cachedIcon{}, fmt.Errorf("icon larger than %d bytes", maxIconBytes)
+	}
+
+	contentType := iconContentType(resp.Header.Get("Content-Type"), iconURL, data)
+	if contentType == "" {
+		return cachedIcon{}, fmt.Errorf("not an image")
+	}
+	return cachedIcon{data: data, contentType: contentType, expiresAt: time.Now().Add(iconCacheTTL)}, nil
+}
+
+// iconContentType picks the image type from the response header, the URL's file
+// extension or the content itself, returning "" when none of them is an image
+func iconContentType(header, iconURL string, data []byte) string {
+	if mediaType, _, _ := strings.Cut(header, ";"); strings.HasPrefix(strings.TrimSpace(mediaType), "image/") {
+		return strings.TrimSpace(mediaType)
+	}
+	if u, err := url.Parse(iconURL); err == nil {
+		if contentType := getContentType(strings.ToLower(u.Path)); strings.HasPrefix(contentType, "image/") {
+			return contentType
+		}
+	}
+	if contentType := http.DetectContentType(data); strings.HasPrefix(contentType, "image/") {
+		return contentType
+	}
+	return ""
+}
+
+// handleIcon serves a proxied icon by the hash handed out in App.Icon
+func handleIcon(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		w.Header().Set("Content-Type", "application/json")
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	icon, ok := icons.get(r.URL.Query().Get("h"))
+	if !ok {
+		w.Header().Set("Content-Type", "application/json")
+		http.Error(w, `{"error":"icon not found"}`, http.StatusNotFound)
+		return
+	}
+
+	// Remote SVGs are served from the portal's origin, so they must not run scripts
+	w.Header().Set("Content-Type", icon.contentType)
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; sandbox")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if icon.placeholder {
+		w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(iconCacheTTL.Seconds())))
+	}
+	w.Write(icon.data)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// iconServer counts requests and answers them with handler
+func iconServer(t *testing.T, handler http.HandlerFunc) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		handler(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &hits
+}
+
+func newTestIconProxy(iconURLs ...string) *iconProxy {
+	p := newIconProxy()
+	p.enabled.Store(true)
+	apps := make([]App, len(iconURLs))
+	for i, u := range iconURLs {
+		apps[i].Icon = u
+	}
+	p.apply(apps)
+	return p
+}
+
+func TestIconProxyCachesFailures(t *testing.T) {
+	srv, hits := iconServer(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusBadGateway)
+	})
+	p := newTestIconProxy(srv.URL + "/icon.png")
+	key := iconKey(srv.URL + "/icon.png")
+
+	for i := 0; i < 5; i++ {
+		icon, ok := p.get(key)
+		if !ok || !icon.placeholder {
+			t.Fatalf("get() = %+v, %v, want the placeholder", icon, ok)
+		}
+	}
+	if n := hits.Load(); n != 1 {
+		t.Errorf("%d fetches for a failing icon, want 1", n)
+	}
+}
+
+func TestIconProxyCollapsesConcurrentFetches(t *testing.T) {
+	srv, hits := iconServer(t, func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("png"))
+	})
+	p := newTestIconProxy(srv.URL + "/icon.png")
+	key := iconKey(srv.URL + "/icon.png")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if icon, ok := p.get(key); !ok || string(icon.data) != "png" {
+				t.Errorf("get() = %q, %v", icon.data, ok)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := hits.Load(); n != 1 {
+		t.Errorf("%d fetches for concurrent misses, want 1", n)
+	}
+}
+
+func TestIconProxyApplyDropsRemovedIcons(t *testing.T) {
+	srv, _ := iconServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("png"))
+	})
+	kept, removed := srv.URL+"/kept.png", srv.URL+"/removed.png"
+	p := newTestIconProxy(kept, removed)
+	p.get(iconKey(removed))
+
+	p.apply([]App{{Icon: kept}})
+	if _, ok := p.get(iconKey(removed)); ok {
+		t.Error("icon of a removed app is still served")
+	}
+	if _, ok := p.cache[iconKey(removed)]; ok {
+		t.Error("icon of a removed app is still cached")
+	}
+	if _, ok := p.get(iconKey(kept)); !ok {
+		t.Error("icon of a remaining app is not served")
+	}
+}
@@ -0,0 +1,377 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+	corev1 "k8s.io/api/core/v1"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	v1 "k8s.io/api/networking/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// watchNamespaces restricts Ingress discovery to these namespaces; empty means all
+var watchNamespaces []string
+
+// ingressLabelSelector limits which Ingresses are listed and watched at all
+var ingressLabelSelector string
+
+// clusterWatchers holds the informers backing a cluster's discovery
+type clusterWatchers struct {
+	ingresses   []cache.SharedIndexInformer
+	enabledApps cache.SharedIndexInformer
+	synced      atomic.Bool
+
+	// dynamic holds the informers of CRD-backed sources, keyed by source
+	dynamic map[string][]cache.SharedIndexInformer
+
+	// lastContact is when the cluster's API server last answered a list or watch, or
+	// delivered a change, in Unix nanoseconds
+	lastContact atomic.Int64
+}
+
+var (
+	// informerResync is how often informers replay their cache through the event
+	// handlers, re-deriving apps, set by INFORMER_RESYNC
+	informerResync = 10 * time.Minute
+
+	// informerStaleAfter is how long a cluster's API server may stay silent before
+	// /readyz fails, set by INFORMER_STALE_AFTER. Healthy informers re-open their
+	// watches every 5 to 10 minutes, so it must stay above that.
+	informerStaleAfter = 30 * time.Minute
+)
+
+// stale reports whether a synced cluster's informers have not heard from the API
+// server for longer than informerStaleAfter, meaning their watches died and never
+// came back. Resyncs replay the local cache and don't count.
+func (c *kubeCluster) stale() bool {
+	if !c.watchers.synced.Load() {
+		return false
+	}
+	return time.Since(time.Unix(0, c.watchers.lastContact.Load())) > informerStaleAfter
+}
+
+// markContact records that the cluster's API server was just heard from
+func (c *kubeCluster) markContact() {
+	c.watchers.lastContact.Store(time.Now().UnixNano())
+}
+
+// apiContactTransport marks the cluster as heard from whenever a list or watch
+// request succeeds; watches only report the moment they are opened
+type apiContactTransport struct {
+	next    http.RoundTripper
+	cluster *kubeCluster
+}
+
+func (t *apiContactTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err == nil && req.Method == http.MethodGet && resp.StatusCode < http.StatusBadRequest {
+		t.cluster.markContact()
+	}
+	return resp, err
+}
+
+// startInformers starts watching Ingresses of the given API version (none when version
+// is empty), the served CRD-backed sources and the enabled apps ConfigMap when
+// configured, and marks the cache synced once the initial lists land
+func (c *kubeCluster) startInformers(clientset kubernetes.Interface, version string, dynamicClient dynamic.Interface, sources map[string]schema.GroupVersionResource) error {
+	stop := make(chan struct{})
+	var pending []*scopedInformer
+
+	// Informers are collected locally and only published to c.watchers once all of
+	// them are set up, so a failed attempt leaves nothing half-registered behind
+	var ingressInformers []cache.SharedIndexInformer
+	dynamicInformers := make(map[string][]cache.SharedIndexInformer)
+	var enabledAppsInformer cache.SharedIndexInformer
+
+	// An empty namespace watches the whole cluster; NAMESPACES gets one informer each
+	scopes := watchNamespaces
+	if len(scopes) == 0 {
+		scopes = []string{metav1.NamespaceAll}
+	}
+
+	// Without an Ingress version the ingress source is disabled
+	ingressScopes := scopes
+	if version == "" {
+		ingressScopes = nil
+	}
+
+	for _, namespace := range ingressScopes {
+		factory := informers.NewSharedInformerFactoryWithOptions(clientset, informerResync,
+			informers.WithNamespace(namespace),
+			informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+				opts.LabelSelector = ingressLabelSelector
+			}),
+		)
+
+		var informer cache.SharedIndexInformer
+		switch version {
+		case ingressNetworkingV1beta1:
+			informer = factory.Networking().V1beta1().Ingresses().Informer()
+		case ingressExtensionsV1beta1:
+			informer = factory.Extensions().V1beta1().Ingresses().Informer()
+		default:
+			informer = factory.Networking().V1().Ingresses().Informer()
+		}
+		if _, err := informer.AddEventHandler(c.changeHandler(sourceIngress)); err != nil {
+			return err
+		}
+
+		scoped, err := c.newScopedInformer("ingresses", namespace, informer, factory.Start)
+		if err != nil {
+			return err
+		}
+		ingressInformers = append(ingressInformers, informer)
+		pending = append(pending, scoped)
+	}
+
+	for source, gvr := range sources {
+		for _, namespace := range scopes {
+			factory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(dynamicClient, informerResync, namespace,
+				func(opts *metav1.ListOptions) {
+					opts.LabelSelector = ingressLabelSelector
+				},
+			)
+			informer := factory.ForResource(gvr).Informer()
+			if _, err := informer.AddEventHandler(c.changeHandler(source)); err != nil {
+				return err
+			}
+
+			scoped, err := c.newScopedInformer(gvr.Resource, namespace, informer, factory.Start)
+			if err != nil {
+				return err
+			}
+			dynamicInformers[source] = append(dynamicInformers[source], informer)
+			pending = append(pending, scoped)
+		}
+	}
+
+	if enabledAppsConfigMap != "" {
+		namespace, name, err := splitConfigMapRef(enabledAppsConfigMap)
+		if err != nil {
+			return err
+		}
+
+		cmFactory := informers.NewSharedInformerFactoryWithOptions(clientset, informerResync,
+			informers.WithNamespace(namespace),
+			informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+				opts.FieldSelector = fields.OneTermEqualSelector("metadata.name", name).String()
+			}),
+		)
+		enabledAppsInformer = cmFactory.Core().V1().ConfigMaps().Informer()
+		if _, err := enabledAppsInformer.AddEventHandler(c.changeHandler("configmap")); err != nil {
+			return err
+		}
+		scoped, err := c.newScopedInformer("configmaps", namespace, enabledAppsInformer, cmFactory.Start)
+		if err != nil {
+			return err
+		}
+		pending = append(pending, scoped)
+	}
+
+	c.watchers.ingresses = ingressInformers
+	c.watchers.dynamic = dynamicInformers
+	c.watchers.enabledApps = enabledAppsInformer
+	go c.syncInformers(pending, stop)
+	return nil
+}
+
+// namespaceConcurrency bounds how many informers run their initial list at once,
+// set by NAMESPACE_CONCURRENCY
+var namespaceConcurrency = 5
+
+// scopedInformer is one informer watching a single kind in a single namespace
+type scopedInformer struct {
+	kind      string
+	namespace string
+	informer  cache.SharedIndexInformer
+	start     func(stop <-chan struct{})
+
+	// listFailed is set when the initial list errors, e.g. on an RBAC denial
+	listFailed atomic.Bool
+}
+
+// newScopedInformer wraps an informer that start will run, logging its list and
+// watch failures with the cluster and namespace they happened in
+func (c *kubeCluster) newScopedInformer(kind, namespace string, informer cache.SharedIndexInformer, start func(stop <-chan struct{})) (*scopedInformer, error) {
+	s := &scopedInformer{kind: kind, namespace: namespace, informer: informer, start: start}
+	err := informer.SetWatchErrorHandler(func(_ *cache.Reflector, err error) {
+		log.Printf("WARNING: Watching %s in %s of cluster %s failed: %v", kind, s.scope(), c, err)
+		if !informer.HasSynced() {
+			s.listFailed.Store(true)
+		}
+	})
+	return s, err
+}
+
+// scope names the informer's namespace in log messages
+func (s *scopedInformer) scope() string {
+	if s.namespace == metav1.NamespaceAll {
+		return "all namespaces"
+	}
+	return "namespace " + s.namespace
+}
+
+// waitForInitialList blocks until the informer has synced or its first list failed,
+// reporting whether it synced
+func (s *scopedInformer) waitForInitialList(stop <-chan struct{}) bool {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		if s.informer.HasSynced() {
+			return true
+		}
+		if s.listFailed.Load() {
+			return false
+		}
+		select {
+		case <-stop:
+			return false
+		case <-ticker.C:
+		}
+	}
+}
+
+// syncInformers starts the informers at most namespaceConcurrency at a time, each
+// holding its slot until its initial list lands or fails. The cluster is marked
+// synced once every informer had its first try, as long as one of them succeeded, so
+// a namespace the service account can't read only hides its own apps. Informers
+// that failed keep retrying in the background.
+func (c *kubeCluster) syncInformers(pending []*scopedInformer, stop <-chan struct{}) {
+	log.Printf("Kubernetes mode: waiting for informer caches of cluster %s to sync", c)
+
+	var g errgroup.Group
+	g.SetLimit(namespaceConcurrency)
+	var synced atomic.Int32
+	for _, s := range pending {
+		s := s
+		g.Go(func() error {
+			s.start(stop)
+			if s.waitForInitialList(stop) {
+				synced.Add(1)
+			}
+			return nil
+		})
+	}
+	g.Wait()
+
+	if failed := len(pending) - int(synced.Load()); failed > 0 {
+		log.Printf("WARNING: %d of %d informers of cluster %s failed their initial list", failed, len(pending), c)
+		if synced.Load() == 0 {
+			syncFuncs := make([]cache.InformerSynced, len(pending))
+			for i, s := range pending {
+				syncFuncs[i] = s.informer.HasSynced
+			}
+			if !cache.WaitForCacheSync(stop, syncFuncs...) {
+				return
+			}
+		}
+	}
+
+	c.markContact()
+	c.watchers.synced.Store(true)
+	invalidateCache()
+	log.Printf("Kubernetes mode: informer caches of cluster %s synced", c)
+}
+
+// changeHandler invalidates the app cache and wakes live streams whenever a watched
+// object changes; events from the initial list are ignored until the cache syncs
+func (c *kubeCluster) changeHandler(kind string) cache.ResourceEventHandlerFuncs {
+	onChange := func() {
+		if !c.watchers.synced.Load() {
+			return
+		}
+		if debugMode {
+			log.Printf("DEBUG: %s changed in cluster %s, refreshing apps", kind, c)
+		}
+		invalidateCache()
+		appsUpdates.notify()
+	}
+
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(interface{}) {
+			c.markContact()
+			onChange()
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			// A periodic resync replays unchanged objects from the local cache, so it
+			// re-derives apps without proving the API server is still there
+			if !sameResourceVersion(oldObj, newObj) {
+				c.markContact()
+			}
+			onChange()
+		},
+		DeleteFunc: func(interface{}) {
+			c.markContact()
+			onChange()
+		},
+	}
+}
+
+// sameResourceVersion reports whether two informer objects are the same revision
+func sameResourceVersion(oldObj, newObj interface{}) bool {
+	oldMeta, err := meta.Accessor(oldObj)
+	if err != nil {
+		return false
+	}
+	newMeta, err := meta.Accessor(newObj)
+	if err != nil {
+		return false
+	}
+	return oldMeta.GetResourceVersion() == newMeta.GetResourceVersion()
+}
+
+// cachedIngresses returns every Ingress in the cluster's informer stores in the networking/v1
+// shape, ordered by namespace and name
+func (c *kubeCluster) cachedIngresses() []v1.Ingress {
+	var objects []interface{}
+	for _, informer := range c.watchers.ingresses {
+		objects = append(objects, informer.GetStore().List()...)
+	}
+
+	ingresses := make([]v1.Ingress, 0, len(objects))
+	for _, obj := range objects {
+		switch ing := obj.(type) {
+		case *v1.Ingress:
+			ingresses = append(ingresses, *ing)
+		case *networkingv1beta1.Ingress:
+			ingresses = append(ingresses, convertNetworkingV1beta1Ingress(*ing))
+		case *extensionsv1beta1.Ingress:
+			ingresses = append(ingresses, convertExtensionsV1beta1Ingress(*ing))
+		}
+	}
+	// Store order is random; namespace/name keeps ties in the later app sort stable
+	sort.Slice(ingresses, func(i, j int) bool {
+		if ingresses[i].Namespace != ingresses[j].Namespace {
+			return ingresses[i].Namespace < ingresses[j].Namespace
+		}
+		return ingresses[i].Name < ingresses[j].Name
+	})
+	return ingresses
+}
+
+// cachedEnabledApps returns the entries of the enabled apps ConfigMap, if one is watched
+func (c *kubeCluster) cachedEnabledApps() enabledApps {
+	if c.watchers.enabledApps == nil {
+		return nil
+	}
+	for _, obj := range c.watchers.enabledApps.GetStore().List() {
+		if cm, ok := obj.(*corev1.ConfigMap); ok {
+			return parseEnabledApps(cm)
+		}
+	}
+	return nil
+}
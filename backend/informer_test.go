@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestSplitConfigMapRef(t *testing.T) {
+	tests := []struct {
+		ref             string
+		namespace, name string
+		ok              bool
+	}{
+		{"portal/enabled-apps", "portal", "enabled-apps", true},
+		{"enabled-apps", "", "", false},
+		{"/enabled-apps", "", "", false},
+		{"portal/", "", "", false},
+		{"portal/a/b", "", "", false},
+	}
+	for _, tt := range tests {
+		namespace, name, err := splitConfigMapRef(tt.ref)
+		if (err == nil) != tt.ok || namespace != tt.namespace || name != tt.name {
+			t.Errorf("splitConfigMapRef(%q) = %q, %q, %v", tt.ref, namespace, name, err)
+		}
+	}
+}
+
+// TestStartInformersFailureRegistersNothing retries a failing start and expects no
+// informers to pile up on the cluster
+func TestStartInformersFailureRegistersNothing(t *testing.T) {
+	saved := enabledAppsConfigMap
+	enabledAppsConfigMap = "not-a-reference"
+	defer func() { enabledAppsConfigMap = saved }()
+
+	cluster := &kubeCluster{}
+	clientset := fake.NewSimpleClientset()
+	for i := 0; i < 2; i++ {
+		if err := cluster.startInformers(clientset, "v1", nil, nil); err == nil {
+			t.Fatal("startInformers() succeeded with an invalid ENABLED_APPS_CONFIGMAP")
+		}
+	}
+	if n := len(cluster.watchers.ingresses); n != 0 {
+		t.Errorf("%d ingress informers registered after failed starts, want 0", n)
+	}
+	if cluster.watchers.dynamic != nil || cluster.watchers.enabledApps != nil {
+		t.Error("dynamic or ConfigMap informers registered after failed starts")
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	v1 "k8s.io/api/networking/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+)
+
+// convertNetworkingV1beta1Ingress copies the fields discovery uses from a
+// networking.k8s.io/v1beta1 Ingress into the v1 shape; backends are not needed
+func convertNetworkingV1beta1Ingress(in networkingv1beta1.Ingress) v1.Ingress {
+	out := v1.Ingress{ObjectMeta: in.ObjectMeta}
+	out.Spec.IngressClassName = in.Spec.IngressClassName
+
+	for _, tls := range in.Spec.TLS {
+		out.Spec.TLS = append(out.Spec.TLS, v1.IngressTLS{Hosts: tls.Hosts, SecretName: tls.SecretName})
+	}
+
+	for _, rule := range in.Spec.Rules {
+		converted := v1.IngressRule{Host: rule.Host}
+		if rule.HTTP != nil {
+			converted.HTTP = &v1.HTTPIngressRuleValue{}
+			for _, path := range rule.HTTP.Paths {
+				converted.HTTP.Paths = append(converted.HTTP.Paths, v1.HTTPIngressPath{
+					Path:     path.Path,
+					PathType: (*v1.PathType)(path.PathType),
+				})
+			}
+		}
+		out.Spec.Rules = append(out.Spec.Rules, converted)
+	}
+	return out
+}
+
+// convertExtensionsV1beta1Ingress copies the fields discovery uses from an
+// extensions/v1beta1 Ingress into the v1 shape; backends are not needed
+func convertExtensionsV1beta1Ingress(in extensionsv1beta1.Ingress) v1.Ingress {
+	out := v1.Ingress{ObjectMeta: in.ObjectMeta}
+	out.Spec.IngressClassName = in.Spec.IngressClassName
+
+	for _, tls := range in.Spec.TLS {
+		out.Spec.TLS = append(out.Spec.TLS, v1.IngressTLS{Hosts: tls.Hosts, SecretName: tls.SecretName})
+	}
+
+	for _, rule := range in.Spec.Rules {
+		converted := v1.IngressRule{Host: rule.Host}
+		if rule.HTTP != nil {
+			converted.HTTP = &v1.HTTPIngressRuleValue{}
+			for _, path := range rule.HTTP.Paths {
+				converted.HTTP.Paths = append(converted.HTTP.Paths, v1.HTTPIngressPath{
+					Path:     path.Path,
+					PathType: (*v1.PathType)(path.PathType),
+				})
+			}
+		}
+		out.Spec.Rules = append(out.Spec.Rules, converted)
+	}
+	return out
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Authentication modes selectable with AUTH_MODE
+const (
+	authModeHeader = "header"
+	authModeJWT    = "jwt"
+)
+
+var (
+	// authMode picks where user groups come from: the groups header set by an auth
+	// proxy, or a bearer JWT verified against JWKS_URL
+	authMode = authModeHeader
+
+	// groupsClaim is the JWT claim holding the user's groups
+	groupsClaim = "groups"
+
+	// jwtIssuer and jwtAudience, when set, must match the token's iss and aud claims
+	jwtIssuer, jwtAudience string
+
+	// jwks caches the signing keys published at JWKS_URL
+	jwks = &jwksCache{}
+)
+
+// jwksRefreshInterval is how long fetched keys are trusted before re-fetching; an
+// unknown key id triggers an early refresh at most once per jwksMinRefresh
+const (
+	jwksRefreshInterval = time.Hour
+	jwksMinRefresh      = time.Minute
+)
+
+// jwksCache holds the public keys of a JWKS endpoint by key id
+type jwksCache struct {
+	mu        sync.Mutex
+	url       string
+	keys      map[string]interface{}
+	fetchedAt time.Time
+}
+
+// key returns the public key for kid, fetching the key set when it is stale or the
+// kid is unknown (keys rotate)
+func (c *jwksCache) key(kid string) (interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	age := time.Since(c.fetchedAt)
+	_, known := c.keys[kid]
+	if age > jwksRefreshInterval || (!known && age > jwksMinRefresh) {
+		if err := c.fetch(); err != nil {
+			if c.keys == nil {
+				return nil, err
+			}
+			log.Printf("WARNING: Refreshing JWKS failed, using cached keys: %v", err)
+		}
+	}
+
+	key, ok := c.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+// fetch downloads and parses the key set; callers must hold c.mu
+func (c *jwksCache) fetch() error {
+	c.fetchedAt = time.Now()
+
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("JWKS endpoint returned HTTP %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("decoding JWKS: %w", err)
+	}
+
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			log.Printf("WARNING: Skipping JWKS key %q: %v", jwk.Kid, err)
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	c.keys = keys
+	log.Printf("Loaded %d signing keys from JWKS", len(keys))
+	return nil
+}
+
+// jsonWebKey is the subset of RFC 7517 needed for RSA and EC signature keys
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// publicKey decodes the key material into an *rsa.PublicKey or *ecdsa.PublicKey
+func (k jsonWebKey) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBase64BigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBase64BigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBase64BigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBase64BigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBase64BigInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(data), nil
+}
+
+// groupsFromJWT verifies the request's bearer token and returns its groups claim.
+// A missing, invalid or expired token yields no groups.
+func groupsFromJWT(r *http.Request) []string {
+	raw, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || strings.TrimSpace(raw) == "" {
+		if debugMode {
+			log.Printf("DEBUG: No bearer token from %s", r.RemoteAddr)
+		}
+		return nil
+	}
+
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}),
+		jwt.WithExpirationRequired(),
+	}
+	if jwtIssuer != "" {
+		options = append(options, jwt.WithIssuer(jwtIssuer))
+	}
+	if jwtAudience != "" {
+		options = append(options, jwt.WithAudience(jwtAudience))
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(strings.TrimSpace(raw), claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return jwks.key(kid)
+	}, options...)
+	if err != nil {
+		log.Printf("WARNING: Rejecting bearer token from %s: %v", r.RemoteAddr, err)
+		return nil
+	}
+
+	groups, err := claimGroups(claims[groupsClaim])
+	if err != nil {
+		log.Printf("WARNING: Ignoring claim %q from %s: %v", groupsClaim, r.RemoteAddr, err)
+		return nil
+	}
+	return groups
+}
+
+// claimGroups reads a groups claim given as a string array or a comma-separated string
+func claimGroups(claim interface{}) ([]string, error) {
+	switch value := claim.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return parseListAnnotation(value), nil
+	case []interface{}:
+		var groups []string
+		for _, item := range value {
+			group, ok := item.(string)
+			if !ok {
+				return nil, errors.New("groups must be strings")
+			}
+			if group = strings.TrimSpace(group); group != "" {
+				groups = append(groups, group)
+			}
+		}
+		return groups, nil
+	default:
+		return nil, fmt.Errorf("unsupported claim type %T", claim)
+	}
+}
@@ -0,0 +1,625 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// Ingress API group versions the portal can discover, newest first
+const (
+	ingressNetworkingV1      = "networking.k8s.io/v1"
+	ingressNetworkingV1beta1 = "networking.k8s.io/v1beta1"
+	ingressExtensionsV1beta1 = "extensions/v1beta1"
+)
+
+// kubeconfigPath is set by the --kubeconfig flag
+var kubeconfigPath string
+
+// k8sTimeout bounds each synchronous Kubernetes API call, under the context of the
+// request that triggered it. Those are only the discovery calls a cluster's client
+// makes on first use or after a failure: apps are served from the informer caches,
+// so once a cluster is connected requests make no API calls at all.
+var k8sTimeout = 5 * time.Second
+
+// ingressClass limits Ingress discovery to one ingress class when set by INGRESS_CLASS
+var ingressClass string
+
+// urlTemplate renders app URLs for objects without a dashboard.home/url-template,
+// set by URL_TEMPLATE; empty keeps the URL derived from the route
+var urlTemplate string
+
+// legacyIngressClassAnnotation predates spec.ingressClassName and is still set by older charts
+const legacyIngressClassAnnotation = "kubernetes.io/ingress.class"
+
+// enabledAppsConfigMap optionally names a ConfigMap that enables apps alongside the annotation
+var enabledAppsConfigMap string
+
+// splitConfigMapRef splits a namespace/name ConfigMap reference such as ENABLED_APPS_CONFIGMAP
+func splitConfigMapRef(ref string) (namespace, name string, err error) {
+	namespace, name, ok := strings.Cut(ref, "/")
+	if !ok || namespace == "" || name == "" || strings.Contains(name, "/") {
+		return "", "", fmt.Errorf("ENABLED_APPS_CONFIGMAP must be namespace/name, got %q", ref)
+	}
+	return namespace, name, nil
+}
+
+// client returns the cluster's clientset and the Ingress API version in use,
+// creating and probing them on first use or after a previous failure; ctx bounds
+// the probing
+func (c *kubeCluster) client(ctx context.Context) (kubernetes.Interface, string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.clientset != nil {
+		return c.clientset, c.ingressVersion, nil
+	}
+
+	config, err := c.restConfig()
+	if err != nil {
+		log.Printf("ERROR: Failed to load Kubernetes config for cluster %s: %v", c, err)
+		return nil, "", err
+	}
+
+	config.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return &listMetricsTransport{next: rt}
+	})
+	config.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return &apiContactTransport{next: rt, cluster: c}
+	})
+
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		log.Printf("ERROR: Failed to create Kubernetes clientset for cluster %s: %v", c, err)
+		return nil, "", err
+	}
+
+	var version string
+	if discoveryEnabled(sourceIngress) {
+		version, err = detectIngressVersion(ctx, clientset.Discovery())
+		if err != nil {
+			log.Printf("ERROR: Failed to detect Ingress API version for cluster %s: %v", c, err)
+			return nil, "", err
+		}
+		log.Printf("Kubernetes mode: cluster %s uses Ingress API %s", c, version)
+	}
+
+	served, err := servedDynamicSources(ctx, clientset.Discovery())
+	if err != nil {
+		log.Printf("ERROR: Failed to discover custom resources for cluster %s: %v", c, err)
+		return nil, "", err
+	}
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		log.Printf("ERROR: Failed to create dynamic Kubernetes client for cluster %s: %v", c, err)
+		return nil, "", err
+	}
+
+	if err := c.startInformers(clientset, version, dynamicClient, served); err != nil {
+		log.Printf("ERROR: Failed to start informers for cluster %s: %v", c, err)
+		return nil, "", err
+	}
+
+	c.clientset = clientset
+	c.ingressVersion = version
+	return clientset, version, nil
+}
+
+// restConfig loads the cluster's kubeconfig and context, or picks the default
+// configuration for a cluster that names none
+func (c *kubeCluster) restConfig() (*rest.Config, error) {
+	if c.kubeconfig == "" {
+		return kubeRESTConfig()
+	}
+	log.Printf("Kubernetes mode: cluster %s using kubeconfig %s (context %q)", c, c.kubeconfig, c.context)
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		&clientcmd.ClientConfigLoadingRules{ExplicitPath: c.kubeconfig},
+		&clientcmd.ConfigOverrides{CurrentContext: c.context},
+	).ClientConfig()
+}
+
+// kubeRESTConfig picks the client configuration: an explicit --kubeconfig or $KUBECONFIG
+// wins, then the in-cluster service account, then ~/.kube/config for local development
+func kubeRESTConfig() (*rest.Config, error) {
+	path := kubeconfigPath
+	if path == "" {
+		path = os.Getenv("KUBECONFIG")
+	}
+	if path != "" {
+		log.Printf("Kubernetes mode: using kubeconfig %s", path)
+		return clientcmd.BuildConfigFromFlags("", path)
+	}
+
+	config, err := rest.InClusterConfig()
+	if err == nil {
+		log.Printf("Kubernetes mode: using in-cluster config")
+		return config, nil
+	}
+
+	home, homeErr := os.UserHomeDir()
+	if homeErr != nil {
+		return nil, err
+	}
+	path = filepath.Join(home, ".kube", "config")
+	if _, statErr := os.Stat(path); statErr != nil {
+		return nil, fmt.Errorf("not running in a cluster (%v) and no kubeconfig at %s", err, path)
+	}
+	log.Printf("Kubernetes mode: not in a cluster, using kubeconfig %s", path)
+	return clientcmd.BuildConfigFromFlags("", path)
+}
+
+// detectIngressVersion asks the discovery API for the newest served Ingress version,
+// retrying transient API errors
+func detectIngressVersion(ctx context.Context, client discovery.DiscoveryInterface) (string, error) {
+	var version string
+	err := retryTransient(ctx, "Ingress API discovery", func() error {
+		var err error
+		version, err = discoverIngressVersion(ctx, client)
+		return err
+	})
+	return version, err
+}
+
+// discoverIngressVersion makes one pass over the known Ingress versions. Only a
+// NotFound moves on to an older version; any other error aborts the pass so a blip
+// can't make the portal settle on a deprecated API.
+func discoverIngressVersion(ctx context.Context, client discovery.DiscoveryInterface) (string, error) {
+	var lastErr error
+	for _, version := range []string{ingressNetworkingV1, ingressNetworkingV1beta1, ingressExtensionsV1beta1} {
+		resources, err := serverResourcesForGroupVersion(ctx, client, version)
+		if apierrors.IsNotFound(err) {
+			lastErr = err
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		for _, resource := range resources.APIResources {
+			if resource.Name == "ingresses" {
+				return version, nil
+			}
+		}
+	}
+	if lastErr != nil {
+		return "", lastErr
+	}
+	return "", fmt.Errorf("no Ingress API served by the cluster")
+}
+
+// serverResourcesForGroupVersion lists the resources an API group version serves,
+// like the discovery client's method of the same name but bounded by ctx and k8sTimeout
+func serverResourcesForGroupVersion(ctx context.Context, client discovery.DiscoveryInterface, groupVersion string) (*metav1.APIResourceList, error) {
+	ctx, cancel := context.WithTimeout(ctx, k8sTimeout)
+	defer cancel()
+
+	path := "/apis/" + groupVersion
+	if groupVersion == "v1" {
+		path = "/api/v1"
+	}
+	resources := &metav1.APIResourceList{}
+	if err := client.RESTClient().Get().AbsPath(path).Do(ctx).Into(resources); err != nil {
+		return nil, err
+	}
+	resources.GroupVersion = groupVersion
+	return resources, nil
+}
+
+// apiRetryBackoff spaces out retries of transient Kubernetes API errors: three
+// attempts over roughly 1.5s
+var apiRetryBackoff = wait.Backoff{Duration: 500 * time.Millisecond, Factor: 2, Jitter: 0.1, Steps: 3}
+
+// retryTransient runs fn with exponential backoff while it fails with a transient
+// error, returning the last error once attempts run out, the error is permanent or
+// ctx is done
+func retryTransient(ctx context.Context, what string, fn func() error) error {
+	var lastErr error
+	attempt := 0
+	err := wait.ExponentialBackoffWithContext(ctx, apiRetryBackoff, func(context.Context) (bool, error) {
+		attempt++
+		lastErr = fn()
+		if lastErr == nil {
+			return true, nil
+		}
+		if !isTransientAPIError(lastErr) {
+			return false, lastErr
+		}
+		if attempt < apiRetryBackoff.Steps {
+			log.Printf("WARNING: %s failed (attempt %d/%d), retrying: %v", what, attempt, apiRetryBackoff.Steps, lastErr)
+		}
+		return false, nil
+	})
+	if wait.Interrupted(err) && lastErr != nil {
+		return lastErr
+	}
+	return err
+}
+
+// isTimeoutError reports whether err comes from a Kubernetes API call running out of time
+func isTimeoutError(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || apierrors.IsTimeout(err) || apierrors.IsServerTimeout(err) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// isTransientAPIError reports whether err is worth retrying: timeouts, throttling,
+// 5xx responses and dropped connections, but not e.g. RBAC or NotFound errors
+func isTransientAPIError(err error) bool {
+	if apierrors.IsServerTimeout(err) || apierrors.IsTimeout(err) || apierrors.IsTooManyRequests(err) ||
+		apierrors.IsInternalError(err) || apierrors.IsServiceUnavailable(err) || apierrors.IsUnexpectedServerError(err) {
+		return true
+	}
+	var status apierrors.APIStatus
+	if errors.As(err, &status) {
+		return status.Status().Code >= http.StatusInternalServerError
+	}
+	if utilnet.IsConnectionRefused(err) || utilnet.IsConnectionReset(err) || utilnet.IsProbableEOF(err) {
+		return true
+	}
+	return isTimeoutError(err)
+}
+
+// enabledApps is the set of app ids and namespace/name references listed in the
+// enabled apps ConfigMap
+type enabledApps map[string]bool
+
+// contains reports whether the app's id or its namespace/name reference is listed
+func (e enabledApps) contains(id, namespace, name string) bool {
+	if len(e) == 0 {
+		return false
+	}
+	return (id != "" && e[id]) || e[namespace+"/"+name]
+}
+
+// parseEnabledApps reads the "apps" key of the enabled apps ConfigMap, which lists
+// app ids or ingress namespace/name references separated by commas or newlines
+func parseEnabledApps(cm *corev1.ConfigMap) enabledApps {
+	enabled := make(enabledApps)
+	for _, line := range strings.Split(cm.Data["apps"], "\n") {
+		for _, entry := range parseListAnnotation(line) {
+			enabled[entry] = true
+		}
+	}
+	return enabled
+}
+
+// apps builds the cluster's apps from the informer caches of every enabled discovery
+// source, so requests never wait on the API server. It also returns the number of
+// Ingresses seen, for the discovered ingresses gauge.
+func (c *kubeCluster) apps(ctx context.Context) ([]App, int, error) {
+	if _, _, err := c.client(ctx); err != nil {
+		discoveryReports.record(c.String(), nil, err)
+		return nil, 0, err
+	}
+	if !c.watchers.synced.Load() {
+		err := errors.New("ingress cache not synced yet")
+		discoveryReports.record(c.String(), nil, err)
+		return nil, 0, err
+	}
+
+	enabledByConfigMap := c.cachedEnabledApps()
+
+	var apps []App
+	var decisions []discoveryDecision
+	var ingressCount int
+	if discoveryEnabled(sourceIngress) {
+		ingresses := c.cachedIngresses()
+		ingressCount = len(ingresses)
+		log.Printf("Kubernetes mode: found %d total ingresses in cluster %s", len(ingresses), c)
+
+		filteredByClass := 0
+		for _, ing := range ingresses {
+			annotations := annotationsFor(sourceIngress, ing.Namespace, ing.Annotations)
+			decision := newDecision(sourceIngress, ing.Namespace, ing.Name, annotations)
+			if class := ingressClassOf(&ing); ingressClass != "" && class != ingressClass {
+				filteredByClass++
+				decisions = append(decisions, decision.skipped(fmt.Sprintf("ingress class %q, INGRESS_CLASS is %q", class, ingressClass)))
+				continue
+			}
+			if reason := skipReason(annotations, enabledByConfigMap, ing.Namespace, ing.Name); reason != "" {
+				decisions = append(decisions, decision.skipped(reason))
+				continue
+			}
+
+			var routes []appRoute
+			for _, rule := range ingressRules(&ing) {
+				routes = append(routes, appRoute{host: rule.Host, url: getIngressURL(&ing, rule)})
+			}
+			found := appsFromRoutes(annotations, ing.Namespace, ing.Name, routes)
+			decisions = append(decisions, decision.included(len(found)))
+			apps = append(apps, found...)
+		}
+		if ingressClass != "" {
+			log.Printf("Kubernetes mode: skipped %d ingresses in cluster %s not of class %q", filteredByClass, c, ingressClass)
+		}
+	}
+
+	if discoveryEnabled(sourceHTTPRoute) {
+		routes := c.cachedHTTPRoutes()
+		log.Printf("Kubernetes mode: found %d total HTTPRoutes in cluster %s", len(routes), c)
+
+		for _, route := range routes {
+			annotations := annotationsFor(sourceHTTPRoute, route.GetNamespace(), route.GetAnnotations())
+			decision := newDecision(sourceHTTPRoute, route.GetNamespace(), route.GetName(), annotations)
+			if reason := skipReason(annotations, enabledByConfigMap, route.GetNamespace(), route.GetName()); reason != "" {
+				decisions = append(decisions, decision.skipped(reason))
+				continue
+			}
+			found := appsFromRoutes(annotations, route.GetNamespace(), route.GetName(), httpRouteHosts(route))
+			decisions = append(decisions, decision.included(len(found)))
+			apps = append(apps, found...)
+		}
+	}
+
+	if discoveryEnabled(sourceIngressRoute) {
+		routes := c.cachedIngressRoutes()
+		log.Printf("Kubernetes mode: found %d total IngressRoutes in cluster %s", len(routes), c)
+
+		for _, route := range routes {
+			annotations := annotationsFor(sourceIngressRoute, route.GetNamespace(), route.GetAnnotations())
+			decision := newDecision(sourceIngressRoute, route.GetNamespace(), route.GetName(), annotations)
+			if reason := skipReason(annotations, enabledByConfigMap, route.GetNamespace(), route.GetName()); reason != "" {
+				decisions = append(decisions, decision.skipped(reason))
+				continue
+			}
+			found := appsFromRoutes(annotations, route.GetNamespace(), route.GetName(), ingressRouteHosts(route))
+			decisions = append(decisions, decision.included(len(found)))
+			apps = append(apps, found...)
+		}
+	}
+	discoveryReports.record(c.String(), decisions, nil)
+
+	for i := range apps {
+		apps[i].Cluster = c.name
+	}
+	return apps, ingressCount, nil
+}
+
+// appRoute is one host a discovered object exposes and the URL derived for it
+type appRoute struct {
+	host string
+	url  string
+}
+
+// appsFromRoutes builds one app per route of the discovered object namespace/name,
+// recording where it came from. Every host after
+// the first becomes its own tile, so titles and ids are kept distinct unless
+// dashboard.home/host-titles names the host.
+func appsFromRoutes(annotations appAnnotations, namespace, name string, routes []appRoute) []App {
+	base := appFromAnnotations(annotations, namespace)
+	base.Namespace = namespace
+	base.Source = name
+	if base.ID == "" {
+		key := name
+		if key == "" {
+			key = base.Title
+		}
+		base.ID = derivedAppID(namespace, key)
+	}
+	hostTitles := parseHostTitles(annotations.getList("host-titles"))
+	urlOverride := annotations.getURL("url")
+	scheme, port := annotations.getScheme(), annotations.getPort()
+	template := annotations.get("url-template")
+	if template == "" {
+		template = urlTemplate
+	}
+
+	var apps []App
+	for i, route := range routes {
+		app := base
+		app.URL = withSchemeAndPort(route.url, scheme, port)
+		if template != "" {
+			app.URL = renderURLTemplate(template, route, namespace, name)
+		}
+		if urlOverride != "" {
+			app.URL = urlOverride
+		}
+
+		if title, ok := hostTitles[strings.ToLower(route.host)]; ok {
+			app.Title = title
+			app.Localized = localizedTitles(app.Localized, func(string) string { return "" })
+		} else if i > 0 {
+			app.Title = fmt.Sprintf("%s (%s)", app.Title, route.host)
+			app.Localized = localizedTitles(app.Localized, func(title string) string {
+				return fmt.Sprintf("%s (%s)", title, route.host)
+			})
+		}
+		if i > 0 {
+			app.ID += "-" + route.host
+		}
+		app.Icon = resolveIcon(app.Icon, app.Title, namespace)
+
+		apps = append(apps, app)
+		log.Printf("Added app: title=%s namespace=%s host=%s groups=%v", app.Title, namespace, route.host, app.Groups)
+	}
+	return apps
+}
+
+// localizedTitles copies the locale variants with every title passed through rename, so
+// translated titles follow the host-titles and "(host)" naming of the base title
+func localizedTitles(texts map[string]appText, rename func(string) string) map[string]appText {
+	if len(texts) == 0 {
+		return texts
+	}
+	renamed := make(map[string]appText, len(texts))
+	for tag, text := range texts {
+		if text.Title != "" {
+			text.Title = rename(text.Title)
+		}
+		renamed[tag] = text
+	}
+	return renamed
+}
+
+// ingressRules returns the rules that produce apps: one per distinct host, skipping
+// host-less catch-all rules unless the ingress has nothing else
+func ingressRules(ing *v1.Ingress) []v1.IngressRule {
+	var rules []v1.IngressRule
+	seen := make(map[string]bool)
+	for _, rule := range ing.Spec.Rules {
+		host := strings.ToLower(rule.Host)
+		if host == "" || seen[host] {
+			continue
+		}
+		seen[host] = true
+		rules = append(rules, rule)
+	}
+
+	if len(rules) == 0 && len(ing.Spec.Rules) > 0 {
+		rules = append(rules, ing.Spec.Rules[0])
+	}
+	return rules
+}
+
+// parseHostTitles parses dashboard.home/host-titles entries of the form "host=Title"
+func parseHostTitles(entries []string) map[string]string {
+	titles := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		host, title, ok := strings.Cut(entry, "=")
+		host, title = strings.TrimSpace(host), strings.TrimSpace(title)
+		if !ok || host == "" || title == "" {
+			log.Printf("WARNING: Ignoring invalid host title %q", entry)
+			continue
+		}
+		titles[strings.ToLower(host)] = title
+	}
+	return titles
+}
+
+// ingressClassOf returns the ingress's spec.ingressClassName, falling back to the
+// legacy kubernetes.io/ingress.class annotation
+func ingressClassOf(ing *v1.Ingress) string {
+	if ing.Spec.IngressClassName != nil && *ing.Spec.IngressClassName != "" {
+		return *ing.Spec.IngressClassName
+	}
+	return strings.TrimSpace(ing.Annotations[legacyIngressClassAnnotation])
+}
+
+// getIngressURL constructs the URL for one ingress rule, using https when its host
+// is covered by the ingress TLS configuration
+func getIngressURL(ing *v1.Ingress, rule v1.IngressRule) string {
+	scheme := "http://"
+	if hostCoveredByTLS(rule.Host, ing.Spec.TLS) {
+		scheme = "https://"
+	}
+	return scheme + rule.Host + ingressPath(rule)
+}
+
+// renderURLTemplate fills the {host}, {namespace}, {name} and {path} placeholders of a
+// dashboard.home/url-template or URL_TEMPLATE for one route, keeping the derived URL
+// when the result is not a valid URL
+func renderURLTemplate(template string, route appRoute, namespace, name string) string {
+	path := ""
+	if u, err := url.Parse(route.url); err == nil {
+		path = u.Path
+	}
+	rendered := strings.NewReplacer(
+		"{host}", route.host,
+		"{namespace}", namespace,
+		"{name}", name,
+		"{path}", path,
+	).Replace(template)
+	if err := checkURLScheme(rendered); err != nil {
+		log.Printf("WARNING: Ignoring URL template %q for %s/%s: %v", template, namespace, name, err)
+		return route.url
+	}
+	return rendered
+}
+
+// withSchemeAndPort applies the dashboard.home/scheme and dashboard.home/port
+// overrides to a derived route URL; empty overrides leave that part unchanged
+func withSchemeAndPort(rawURL, scheme, port string) string {
+	if scheme == "" && port == "" {
+		return rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	if scheme != "" {
+		u.Scheme = scheme
+	}
+	if port != "" {
+		u.Host = net.JoinHostPort(u.Hostname(), port)
+	}
+	return u.String()
+}
+
+// ingressPath returns the rule's first HTTP path as a clickable prefix, so nginx's
+// "/sonarr(/|$)(.*)" links to "/sonarr" and "/*" to the host root
+func ingressPath(rule v1.IngressRule) string {
+	if rule.HTTP == nil || len(rule.HTTP.Paths) == 0 {
+		return ""
+	}
+	return cleanRoutePath(rule.HTTP.Paths[0].Path)
+}
+
+// cleanRoutePath turns a route path into a clickable prefix, cutting regex captures and
+// wildcards at their first special character; the root path yields ""
+func cleanRoutePath(path string) string {
+	if i := strings.IndexAny(path, "()*[]{}?+|^$\\"); i >= 0 {
+		path = strings.TrimSuffix(path[:i], ".")
+	}
+	if path == "/" || !strings.HasPrefix(path, "/") {
+		return ""
+	}
+	return path
+}
+
+// hostCoveredByTLS reports whether any TLS block serves the host. A TLS block without
+// hosts uses the controller's default certificate and is treated as covering every host.
+func hostCoveredByTLS(host string, tls []v1.IngressTLS) bool {
+	if tlsMatchAny {
+		return len(tls) > 0
+	}
+
+	for _, t := range tls {
+		if len(t.Hosts) == 0 {
+			return true
+		}
+		for _, pattern := range t.Hosts {
+			if tlsHostMatches(pattern, host) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// tlsHostMatches matches a host against a TLS host, where "*.example.com" covers
+// exactly one extra label (app.example.com but not example.com or a.b.example.com)
+func tlsHostMatches(pattern, host string) bool {
+	pattern = strings.ToLower(strings.TrimSuffix(pattern, "."))
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	if pattern == host {
+		return true
+	}
+
+	if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+		label, rest, found := strings.Cut(host, ".")
+		return found && label != "" && rest == suffix
+	}
+	return false
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/networking/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+func TestTLSHostMatches(t *testing.T) {
+	tests := []struct {
+		pattern, host string
+		want          bool
+	}{
+		{"*.example.com", "app.example.com", true},
+		{"*.example.com", "a.b.example.com", false},
+		{"*.example.com", "example.com", false},
+		{"*.example.com", ".example.com", false},
+		{"app.example.com", "app.example.com", true},
+		{"App.Example.COM", "app.example.com", true},
+		{"*.EXAMPLE.com", "APP.example.COM", true},
+		{"app.example.com.", "app.example.com", true},
+		{"*.example.com", "app.example.com.", true},
+		{"other.example.com", "app.example.com", false},
+	}
+	for _, tt := range tests {
+		if got := tlsHostMatches(tt.pattern, tt.host); got != tt.want {
+			t.Errorf("tlsHostMatches(%q, %q) = %v, want %v", tt.pattern, tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestGetIngressURLScheme(t *testing.T) {
+	tests := []struct {
+		name string
+		host string
+		tls  []v1.IngressTLS
+		want string
+	}{
+		{
+			name: "wildcard covers host",
+			host: "app.example.com",
+			tls:  []v1.IngressTLS{{Hosts: []string{"*.example.com"}}},
+			want: "https://app.example.com",
+		},
+		{
+			name: "wildcard does not cover nested host",
+			host: "a.b.example.com",
+			tls:  []v1.IngressTLS{{Hosts: []string{"*.example.com"}}},
+			want: "http://a.b.example.com",
+		},
+		{
+			name: "wildcard does not cover apex",
+			host: "example.com",
+			tls:  []v1.IngressTLS{{Hosts: []string{"*.example.com"}}},
+			want: "http://example.com",
+		},
+		{
+			name: "TLS block lists other hosts",
+			host: "app.example.com",
+			tls:  []v1.IngressTLS{{Hosts: []string{"other.example.com"}, SecretName: "other-tls"}},
+			want: "http://app.example.com",
+		},
+		{
+			name: "no TLS",
+			host: "app.example.com",
+			want: "http://app.example.com",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := v1.IngressRule{Host: tt.host}
+			ing := &v1.Ingress{Spec: v1.IngressSpec{TLS: tt.tls, Rules: []v1.IngressRule{rule}}}
+			if got := getIngressURL(ing, rule); got != tt.want {
+				t.Errorf("getIngressURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeAPIServer answers discovery for networking.k8s.io/v1, or hangs until the
+// request is cancelled when hang is set
+func fakeAPIServer(t *testing.T, hang bool) kubernetes.Interface {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hang {
+			<-r.Context().Done()
+			return
+		}
+		if r.URL.Path != "/apis/networking.k8s.io/v1" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"kind":"APIResourceList","apiVersion":"v1","groupVersion":"networking.k8s.io/v1","resources":[{"name":"ingresses","namespaced":true,"kind":"Ingress","verbs":["list","watch"]}]}`)
+	}))
+	t.Cleanup(server.Close)
+
+	clientset, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return clientset
+}
+
+func TestDetectIngressVersion(t *testing.T) {
+	version, err := detectIngressVersion(context.Background(), fakeAPIServer(t, false).Discovery())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != ingressNetworkingV1 {
+		t.Errorf("version = %q, want %q", version, ingressNetworkingV1)
+	}
+}
+
+func TestDetectIngressVersionHonorsContext(t *testing.T) {
+	savedTimeout, savedBackoff := k8sTimeout, apiRetryBackoff
+	defer func() { k8sTimeout, apiRetryBackoff = savedTimeout, savedBackoff }()
+	apiRetryBackoff.Duration = 10 * time.Millisecond
+	client := fakeAPIServer(t, true).Discovery()
+
+	t.Run("K8S_TIMEOUT", func(t *testing.T) {
+		k8sTimeout = 50 * time.Millisecond
+		start := time.Now()
+		_, err := detectIngressVersion(context.Background(), client)
+		if !isTimeoutError(err) {
+			t.Errorf("err = %v, want a timeout", err)
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("took %s, want about %d attempts of %s", elapsed, apiRetryBackoff.Steps, k8sTimeout)
+		}
+	})
+
+	t.Run("cancelled request", func(t *testing.T) {
+		k8sTimeout = time.Minute
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(50*time.Millisecond, cancel)
+		start := time.Now()
+		_, err := detectIngressVersion(ctx, client)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("err = %v, want context.Canceled", err)
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("took %s after the request was cancelled", elapsed)
+		}
+	})
+}
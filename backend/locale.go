@@ -0,0 +1,106 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// appText is an app's title and description in one locale
+type appText struct {
+	Title       string
+	Description string
+}
+
+// getLocalized collects the per-locale variants of the title and description
+// annotations, e.g. dashboard.home/title.fr, keyed by canonical language tag.
+// Suffixes that are not valid BCP 47 tags are ignored.
+func (a appAnnotations) getLocalized() map[string]appText {
+	var texts map[string]appText
+	for key, value := range a.values {
+		name, ok := strings.CutPrefix(key, a.prefix)
+		if !ok {
+			continue
+		}
+		field, suffix, ok := strings.Cut(name, ".")
+		if !ok || (field != "title" && field != "description") {
+			continue
+		}
+		tag, err := language.Parse(suffix)
+		if err != nil {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+
+		if texts == nil {
+			texts = make(map[string]appText)
+		}
+		text := texts[tag.String()]
+		if field == "title" {
+			text.Title = value
+		} else {
+			text.Description = value
+		}
+		texts[tag.String()] = text
+	}
+	return texts
+}
+
+// acceptedLanguages parses the request's Accept-Language header, best match first
+func acceptedLanguages(r *http.Request) []language.Tag {
+	tags, _, err := language.ParseAcceptLanguage(r.Header.Get("Accept-Language"))
+	if err != nil {
+		return nil
+	}
+	return tags
+}
+
+// localizeApps returns a copy of apps with titles and descriptions swapped for the
+// closest locale variant the client accepts; fields without a variant keep the base
+// annotation. The input may be the shared cache, so it is never modified.
+func localizeApps(apps []App, accepted []language.Tag) []App {
+	if len(accepted) == 0 {
+		return apps
+	}
+
+	localized := make([]App, len(apps))
+	for i, app := range apps {
+		if text, ok := app.localizedText(accepted); ok {
+			if text.Title != "" {
+				app.Title = text.Title
+			}
+			if text.Description != "" {
+				app.Description = text.Description
+				app.DescriptionHTML = renderDescription(text.Description)
+			}
+		}
+		localized[i] = app
+	}
+	return localized
+}
+
+// localizedText negotiates the app's locale variants against the accepted languages.
+// The base annotations sit first in the matcher as its fallback, so a client that
+// accepts none of the variants gets them.
+func (app App) localizedText(accepted []language.Tag) (appText, bool) {
+	if len(app.Localized) == 0 {
+		return appText{}, false
+	}
+
+	keys := make([]string, 0, len(app.Localized))
+	supported := []language.Tag{language.Und}
+	for key := range app.Localized {
+		keys = append(keys, key)
+		supported = append(supported, language.Make(key))
+	}
+
+	_, index, confidence := language.NewMatcher(supported).Match(accepted...)
+	if index == 0 || confidence == language.No {
+		return appText{}, false
+	}
+	return app.Localized[keys[index-1]], true
+}
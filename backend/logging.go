@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// setupLogging installs the LOG_FORMAT handler. In json mode the standard logger is
+// bridged into slog so existing log.Printf lines come out as structured records too.
+func setupLogging(format string) {
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "", "text":
+		return
+	case "json":
+	default:
+		log.Printf("WARNING: Unknown LOG_FORMAT %q, using text", format)
+		return
+	}
+
+	level := slog.LevelInfo
+	if debugMode {
+		level = slog.LevelDebug
+	}
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+	slog.SetDefault(logger)
+
+	log.SetFlags(0)
+	log.SetOutput(slogWriter{logger})
+}
+
+// logLevelPrefixes maps the repo's log.Printf prefixes onto slog levels
+var logLevelPrefixes = []struct {
+	prefix string
+	level  slog.Level
+}{
+	{"DEBUG: ", slog.LevelDebug},
+	{"WARNING: ", slog.LevelWarn},
+	{"ERROR: ", slog.LevelError},
+	{"ERROR ", slog.LevelError},
+}
+
+// slogWriter turns standard logger output into slog records, taking the level from
+// the message prefix
+type slogWriter struct {
+	logger *slog.Logger
+}
+
+func (s slogWriter) Write(p []byte) (int, error) {
+	msg := strings.TrimSuffix(string(p), "\n")
+	level := slog.LevelInfo
+	for _, l := range logLevelPrefixes {
+		if rest, ok := strings.CutPrefix(msg, l.prefix); ok {
+			msg, level = rest, l.level
+			break
+		}
+	}
+	s.logger.Log(context.Background(), level, msg)
+	return len(p), nil
+}
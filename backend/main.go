@@ -1,59 +1,400 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"embed"
+	"encoding/hex"
 	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
 	"io/fs"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"path"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"gopkg.in/yaml.v3"
-	v1 "k8s.io/api/networking/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/rest"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 //go:embed static/*
 var staticFiles embed.FS
 
 type Config struct {
-	Groups    string          `yaml:"groups"`
-	Ingresses []IngressConfig `yaml:"ingresses"`
+	// The top-level title, logoUrl, theme and accentColor brand the portal
+	portalBranding `yaml:",inline"`
+
+	Groups            string                      `yaml:"groups"`
+	Ingresses         []IngressConfig             `yaml:"ingresses"`
+	NamespaceDefaults map[string]NamespaceDefault `yaml:"namespaceDefaults"`
 }
 
 type IngressConfig struct {
+	Namespace   string            `yaml:"namespace"`
+	Annotations map[string]string `yaml:"annotations"`
+}
+
+// NamespaceDefault supplies values for apps in a namespace that lack their own
+type NamespaceDefault struct {
+	Icon     string `yaml:"icon"`
+	Category string `yaml:"category"`
+	// Annotations are default dashboard annotation values keyed without the prefix,
+	// e.g. groups: admin; a resource's own annotations override them
 	Annotations map[string]string `yaml:"annotations"`
 }
 
 type App struct {
+	ID          string   `json:"id,omitempty"`
 	Title       string   `json:"title"`
 	Icon        string   `json:"icon"`
 	URL         string   `json:"url"`
+	NewTab      bool     `json:"newTab"`
 	Groups      []string `json:"groups"`
+	Match       string   `json:"match"`
+	DenyGroups  []string `json:"denyGroups,omitempty"`
 	Description string   `json:"description"`
+	// DescriptionHTML is the sanitized rendering of Description with DESCRIPTION_FORMAT=markdown
+	DescriptionHTML string   `json:"descriptionHtml,omitempty"`
+	Category        string   `json:"category"`
+	Tags            []string `json:"tags,omitempty"`
+	Weight          *int     `json:"weight,omitempty"`
+	Badge           int      `json:"badge,omitempty"`
+	URLValid        bool     `json:"urlValid"`
+	URLError        string   `json:"urlError,omitempty"`
+
+	// Namespace and Source name the object an app was discovered from; they are only
+	// shown to admins, or to everyone with LOG_LEVEL=DEBUG
+	Namespace string `json:"namespace,omitempty"`
+	Source    string `json:"source,omitempty"`
+	// Cluster names the CLUSTERS entry an app was discovered in
+	Cluster string `json:"cluster,omitempty"`
+	// Localized holds the title.<lang> and description.<lang> variants, picked per
+	// request by Accept-Language
+	Localized map[string]appText `json:"-"`
+	// Favorite marks apps the calling user pinned, when FAVORITES_STORE is set
+	Favorite bool `json:"favorite,omitempty"`
+
+	// Status and LastChecked report the background health check, when enabled
+	Status      string     `json:"status,omitempty"`
+	LastChecked *time.Time `json:"lastChecked,omitempty"`
+	// Circuit is the health check circuit breaker state: closed, open or half-open
+	Circuit string `json:"circuit,omitempty"`
+	// CertExpiry is when the certificate of an https app expires, as seen by the health check
+	CertExpiry *time.Time `json:"certExpiry,omitempty"`
+
+	// BadgeURL and BadgePath locate the badge count fetched in the background
+	BadgeURL  string `json:"-"`
+	BadgePath string `json:"-"`
+	// HealthCheckPath overrides the URL probed by the health checker
+	HealthCheckPath string `json:"-"`
 }
 
+// anyGroup in dashboard.home/groups shows an app to every authenticated user, i.e.
+// anyone with at least one group
+const anyGroup = "*"
+
+// Group match modes for dashboard.home/match
+const (
+	// matchAny shows an app to users in at least one of its groups
+	matchAny = "any"
+	// matchAll shows an app only to users in every one of its groups
+	matchAll = "all"
+)
+
+// defaultAnnotationPrefix is used by every source without a configured prefix
+const defaultAnnotationPrefix = "dashboard.home/"
+
 var (
-	demoMode   bool
-	demoGroups []string
-	staticFS   fs.FS
-	debugMode  bool
+	demoMode  bool
+	staticFS  fs.FS
+	debugMode bool
+
+	// tlsMatchAny restores the legacy behavior of using https whenever any TLS block exists
+	tlsMatchAny bool
+
+	// groupsHeaderName is the request header the auth proxy puts user groups in
+	groupsHeaderName = "X-Forwarded-Groups"
+
+	// userHeaderName is the request header the auth proxy puts the username in
+	userHeaderName = "X-Forwarded-User"
+
+	// includeUser wraps the /api/apps response in an envelope carrying the username
+	includeUser bool
+
+	// groupsDelimiter separates groups in the groups header; whitespace splits on any run of spaces
+	groupsDelimiter = ","
+
+	// adminGroups are the groups allowed to use administrative endpoints
+	adminGroups []string
+
+	// defaultDeny limits users without groups to apps that have no group restriction
+	defaultDeny bool
+
+	// maintenanceMode freezes discovery and serves the last good apps
+	maintenanceMode atomic.Bool
+
+	// annotationPrefixes maps a discovery source to its annotation prefix
+	annotationPrefixes = map[string]string{}
+
+	// defaultCategory labels apps that have no category of their own
+	defaultCategory = "Other"
+
+	// categorySources is the ordered list of places a category is taken from
+	categorySources = []string{"annotation"}
 )
 
 func main() {
+	flag.StringVar(&kubeconfigPath, "kubeconfig", "", "path to a kubeconfig for running outside the cluster (overrides $KUBECONFIG)")
+	validateOnly := flag.Bool("validate", false, "validate the config file and exit (same as CONFIG_VALIDATE=true)")
+	flag.Parse()
+
 	demoMode = os.Getenv("DEMO_MODE") == "true"
 	logLevel := strings.ToUpper(os.Getenv("LOG_LEVEL"))
 	debugMode = logLevel == "DEBUG"
+	setupLogging(os.Getenv("LOG_FORMAT"))
+
+	if category := strings.TrimSpace(os.Getenv("DEFAULT_CATEGORY")); category != "" {
+		defaultCategory = category
+	}
+
+	if sources := os.Getenv("CATEGORY_SOURCE"); sources != "" {
+		categorySources = parseCategorySources(sources)
+	}
+
+	if header := strings.TrimSpace(os.Getenv("GROUPS_HEADER")); header != "" {
+		groupsHeaderName = header
+	}
+	if delimiter := os.Getenv("GROUPS_DELIMITER"); delimiter != "" {
+		groupsDelimiter = delimiter
+	}
+	if header := strings.TrimSpace(os.Getenv("USER_HEADER")); header != "" {
+		userHeaderName = header
+	}
+	includeUser = os.Getenv("INCLUDE_USER") == "true"
+
+	branding = portalBranding{
+		Title:       strings.TrimSpace(os.Getenv("PORTAL_TITLE")),
+		LogoURL:     strings.TrimSpace(os.Getenv("PORTAL_LOGO_URL")),
+		Theme:       strings.ToLower(strings.TrimSpace(os.Getenv("PORTAL_THEME"))),
+		AccentColor: strings.TrimSpace(os.Getenv("PORTAL_ACCENT_COLOR")),
+	}
+	for _, problem := range branding.problems() {
+		log.Printf("WARNING: Ignoring PORTAL_* setting: %s", problem)
+	}
+
+	if kind := strings.ToLower(strings.TrimSpace(os.Getenv("FAVORITES_STORE"))); kind != "" {
+		path := strings.TrimSpace(os.Getenv("FAVORITES_FILE"))
+		if path == "" {
+			path = "favorites.json"
+		}
+		store, err := newFavoritesStore(kind, path)
+		if err != nil {
+			log.Fatalf("Failed to open favorites store: %v", err)
+		}
+		favorites = store
+		log.Printf("Favorites enabled (store=%s file=%s)", kind, path)
+	}
+	adminGroups = splitGroups(os.Getenv("ADMIN_GROUPS"))
+
+	switch mode := strings.ToLower(strings.TrimSpace(os.Getenv("AUTH_MODE"))); mode {
+	case "", authModeHeader:
+	case authModeJWT:
+		authMode = authModeJWT
+		jwks.url = strings.TrimSpace(os.Getenv("JWKS_URL"))
+		if jwks.url == "" {
+			log.Fatalf("AUTH_MODE=jwt requires JWKS_URL")
+		}
+		if claim := strings.TrimSpace(os.Getenv("GROUPS_CLAIM")); claim != "" {
+			groupsClaim = claim
+		}
+		jwtIssuer = strings.TrimSpace(os.Getenv("JWT_ISSUER"))
+		jwtAudience = strings.TrimSpace(os.Getenv("JWT_AUDIENCE"))
+		log.Printf("Reading groups from JWT claim %q (JWKS %s)", groupsClaim, jwks.url)
+	default:
+		log.Fatalf("Invalid AUTH_MODE %q, expected header or jwt", mode)
+	}
+	defaultDeny = os.Getenv("DEFAULT_DENY") == "true"
+	if authMode == authModeJWT && !defaultDeny {
+		log.Printf("WARNING: AUTH_MODE=jwt without DEFAULT_DENY=true shows every app to requests without a valid token")
+	}
+	dedupeByTitle = os.Getenv("DEDUPE") == "true"
+	defaultIcon = strings.TrimSpace(os.Getenv("DEFAULT_ICON"))
+	maintenanceMode.Store(os.Getenv("MAINTENANCE_MODE") == "true")
+	tlsMatchAny = strings.ToLower(os.Getenv("TLS_HOST_MATCH")) == "any"
+
+	if prefixes := os.Getenv("ANNOTATION_PREFIXES"); prefixes != "" {
+		annotationPrefixes = parseAnnotationPrefixes(prefixes)
+		log.Printf("Using annotation prefixes: %v", annotationPrefixes)
+	}
+
+	if ttl := os.Getenv("CACHE_TTL"); ttl == "0" {
+		cacheTTL = 0
+	} else {
+		cacheTTL = parseDurationEnv("CACHE_TTL", cacheTTL)
+	}
+	requestTimeout = parseDurationEnv("REQUEST_TIMEOUT", requestTimeout)
+	if schemes := os.Getenv("ALLOWED_URL_SCHEMES"); schemes != "" {
+		allowedURLSchemes = parseURLSchemes(schemes)
+	}
+	urlDNSCheck = os.Getenv("URL_DNS_CHECK") == "true"
+	urlDNSTimeout = parseDurationEnv("URL_DNS_TIMEOUT", urlDNSTimeout)
+
+	streamInterval = parseDurationEnv("STREAM_POLL_INTERVAL", streamInterval)
+	maxStreams = parseIntEnv("MAX_STREAMS", maxStreams)
+
+	if path := os.Getenv("GROUP_MAPPING_FILE"); path != "" {
+		groupHierarchy.path = path
+		if err := groupHierarchy.load(); err != nil {
+			log.Printf("WARNING: Failed to load group mapping: %v", err)
+		}
+	}
+
+	if err := openAuditSink(os.Getenv("AUDIT_LOG")); err != nil {
+		log.Fatalf("Failed to configure audit log: %v", err)
+	}
+
+	watchNamespaces = parseListAnnotation(os.Getenv("NAMESPACES"))
+	if len(watchNamespaces) > 0 {
+		log.Printf("Restricting discovery to namespaces: %v", watchNamespaces)
+	}
+	namespaceConcurrency = parseIntEnv("NAMESPACE_CONCURRENCY", namespaceConcurrency)
+
+	if selector := strings.TrimSpace(os.Getenv("INGRESS_LABEL_SELECTOR")); selector != "" {
+		if _, err := labels.Parse(selector); err != nil {
+			log.Fatalf("Invalid INGRESS_LABEL_SELECTOR %q: %v", selector, err)
+		}
+		ingressLabelSelector = selector
+		log.Printf("Filtering ingresses by label selector: %s", selector)
+	}
+
+	urlTemplate = strings.TrimSpace(os.Getenv("URL_TEMPLATE"))
+
+	if class := strings.TrimSpace(os.Getenv("INGRESS_CLASS")); class != "" {
+		ingressClass = class
+		log.Printf("Filtering ingresses by class: %s", class)
+	}
+
+	enabledAppsConfigMap = strings.TrimSpace(os.Getenv("ENABLED_APPS_CONFIGMAP"))
+	if enabledAppsConfigMap != "" {
+		if _, _, err := splitConfigMapRef(enabledAppsConfigMap); err != nil {
+			log.Fatalf("Invalid ENABLED_APPS_CONFIGMAP: %v", err)
+		}
+	}
+	if sources := os.Getenv("DISCOVERY_SOURCES"); sources != "" {
+		discoverySources = parseDiscoverySources(sources)
+		log.Printf("Discovery sources: %v", discoverySources)
+	}
+	k8sTimeout = parseDurationEnv("K8S_TIMEOUT", k8sTimeout)
+	informerResync = parseDurationEnv("INFORMER_RESYNC", informerResync)
+	informerStaleAfter = parseDurationEnv("INFORMER_STALE_AFTER", informerStaleAfter)
+	if informerStaleAfter < 10*time.Minute {
+		log.Printf("WARNING: INFORMER_STALE_AFTER=%s is below the 10m watch reconnect interval, /readyz may flap", informerStaleAfter)
+	}
+	if value := strings.TrimSpace(os.Getenv("CLUSTERS")); value != "" {
+		parsed, err := parseClusters(value)
+		if err != nil {
+			log.Fatalf("Invalid CLUSTERS: %v", err)
+		}
+		clusters = parsed
+		log.Printf("Aggregating apps from %d clusters", len(clusters))
+	}
+
+	if path := os.Getenv("NAMESPACE_DEFAULTS_FILE"); path != "" {
+		if err := loadNamespaceDefaults(path); err != nil {
+			log.Printf("WARNING: Failed to load namespace defaults: %v", err)
+		}
+	}
+
+	// CONFIG_PATH pins the config file, with no fallback to the default locations
+	if path := strings.TrimSpace(os.Getenv("CONFIG_PATH")); path != "" {
+		demoConfigPaths = []string{path}
+	}
+
+	if *validateOnly || os.Getenv("CONFIG_VALIDATE") == "true" {
+		problems := validateDemoConfig()
+		for _, problem := range problems {
+			log.Printf("ERROR: %s", problem)
+		}
+		if len(problems) > 0 {
+			log.Fatalf("Config validation failed with %d problem(s)", len(problems))
+		}
+		log.Printf("Config is valid")
+		return
+	}
 
 	if demoMode {
-		loadDemoGroups()
+		if domain := strings.TrimSpace(os.Getenv("DEMO_BASE_DOMAIN")); domain != "" {
+			demoBaseDomain = domain
+		}
+		if err := demoConfig.load(); err != nil {
+			log.Printf("WARNING: Failed to load demo config: %v", err)
+		}
+		go reloadDemoConfigOnSIGHUP()
+		go watchDemoConfig()
+	} else if err := connectClusters(context.Background()); err != nil {
+		// Discovery retries on the next request, so a slow API server at boot isn't fatal
+		log.Printf("WARNING: Kubernetes client not ready at startup: %v", err)
+	}
+
+	log.Printf("Starting portal server %s (commit=%s built=%s DEMO_MODE=%v DEBUG=%v)", version, gitCommit, buildDate, demoMode, debugMode)
+	if maintenanceMode.Load() {
+		log.Printf("Maintenance mode enabled: discovery is frozen")
+	}
+
+	go appsUpdates.run(streamInterval)
+
+	fetchTimeout = parseDurationEnv("FETCH_TIMEOUT", fetchTimeout)
+	workers := parseIntEnv("FETCH_WORKERS", defaultFetchWorkers)
+	backgroundFetches.start(workers)
+
+	if os.Getenv("ENABLE_BADGES") == "true" {
+		interval := parseDurationEnv("BADGE_INTERVAL", time.Minute)
+		log.Printf("Badge fetcher enabled (interval=%s timeout=%s workers=%d)", interval, fetchTimeout, workers)
+		go badges.run(interval)
+	}
+
+	registerMetrics()
+
+	if os.Getenv("ENABLE_HEALTH_CHECKS") == "true" {
+		interval := parseDurationEnv("HEALTH_CHECK_INTERVAL", 30*time.Second)
+		healthCheckTimeout = parseDurationEnv("HEALTH_CHECK_TIMEOUT", fetchTimeout)
+		circuitFailureThreshold = parseIntEnv("HEALTH_CHECK_FAILURE_THRESHOLD", circuitFailureThreshold)
+		circuitCooldown = parseDurationEnv("HEALTH_CHECK_COOLDOWN", circuitCooldown)
+		if os.Getenv("HEALTH_CHECK_INSECURE_SKIP_VERIFY") == "true" {
+			healthChecks.skipTLSVerify()
+			log.Printf("WARNING: Health checks skip TLS certificate verification")
+		}
+		log.Printf("Health checker enabled (interval=%s timeout=%s workers=%d)", interval, healthCheckTimeout, workers)
+		go healthChecks.run(interval)
 	}
 
-	log.Printf("Starting portal server (DEMO_MODE=%v DEBUG=%v)", demoMode, debugMode)
+	if os.Getenv("ICON_PROXY") == "true" {
+		iconCacheTTL = parseDurationEnv("ICON_CACHE_TTL", iconCacheTTL)
+		iconCacheSize = parseIntEnv("ICON_CACHE_SIZE", iconCacheSize)
+		iconFailureTTL = parseDurationEnv("ICON_FAILURE_TTL", iconFailureTTL)
+		icons.enabled.Store(true)
+		log.Printf("Icon proxy enabled (ttl=%s size=%d)", iconCacheTTL, iconCacheSize)
+	}
+
+	switch format := strings.ToLower(strings.TrimSpace(os.Getenv("DESCRIPTION_FORMAT"))); format {
+	case "", "text":
+	case "markdown":
+		descriptionFormat = format
+		log.Printf("Rendering app descriptions as markdown")
+	default:
+		log.Printf("WARNING: Unknown DESCRIPTION_FORMAT %q, descriptions stay plain text", format)
+	}
 
 	// Initialize static file system
 	var err error
@@ -61,23 +402,119 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to load static files: %v", err)
 	}
+	staticETags, err = loadStaticETags(staticFS)
+	if err != nil {
+		log.Fatalf("Failed to hash static files: %v", err)
+	}
+
+	switch value := strings.ToUpper(strings.TrimSpace(os.Getenv("FRAME_OPTIONS"))); value {
+	case "":
+	case "SAMEORIGIN", "DENY":
+		frameOptions = value
+	default:
+		log.Printf("WARNING: Invalid FRAME_OPTIONS %q, using %s", value, frameOptions)
+	}
+	if csp, ok := os.LookupEnv("CSP"); ok {
+		contentSecurityPolicy = strings.TrimSpace(csp)
+	}
+
+	allowedOrigins = parseListAnnotation(os.Getenv("ALLOWED_ORIGINS"))
+	if len(allowedOrigins) > 0 {
+		log.Printf("CORS enabled for origins: %v", allowedOrigins)
+	}
+
+	// Rate limits are per client IP; static assets get their own, usually looser, limit
+	trustForwardedFor = os.Getenv("RATE_LIMIT_TRUST_FORWARDED") == "true"
+	trustedProxyHops = parseIntEnv("RATE_LIMIT_TRUSTED_HOPS", trustedProxyHops)
+	apiLimiter := rateLimiterFromEnv("RATE_LIMIT")
+	staticLimiter := rateLimiterFromEnv("STATIC_RATE_LIMIT")
+
+	// A private mux keeps handlers that packages register on http.DefaultServeMux
+	// (net/http/pprof does so on import) off the listener
+	mux := http.NewServeMux()
 
 	// API endpoints
-	http.HandleFunc("/api/apps", handleApps)
-	http.HandleFunc("/health", handleHealth)
+	mux.Handle("/api/apps", countAppsRequests(withCORS(withRateLimit(apiLimiter, withSecurityHeaders(withGzip(withTimeout(handleApps)))))))
+	mux.Handle("/api/apps/stream", withCORS(http.HandlerFunc(handleAppsStream)))
+	mux.Handle("/api/apps/", withCORS(withRateLimit(apiLimiter, withTimeout(handleAppByID))))
+	mux.Handle("/api/icon", withRateLimit(apiLimiter, withTimeout(handleIcon)))
+	mux.Handle("/api/config", withCORS(withTimeout(handlePortalConfig)))
+	mux.Handle("/api/favorites", withCORS(withRateLimit(apiLimiter, withTimeout(handleFavorites))))
+	mux.Handle("/api/maintenance", withCORS(withTimeout(handleMaintenance)))
+	mux.Handle("/api/groups", withCORS(withTimeout(handleGroups)))
+	mux.Handle("/api/stats/groups", withCORS(withTimeout(handleGroupStats)))
+	mux.HandleFunc("/livez", handleLivez)
+	mux.HandleFunc("/readyz", handleReadyz)
+	// /health predates the split probes and stays a liveness alias
+	mux.HandleFunc("/health", handleLivez)
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/version", handleVersion)
+	if os.Getenv("ENABLE_PPROF") == "true" {
+		pprofEnabled = true
+		registerPprof(mux)
+	}
+	mux.Handle("/debug/discovery", withTimeout(handleDiscoveryDebug))
 
 	// Static file handler
-	http.HandleFunc("/", serveStatic)
+	mux.Handle("/", withRateLimit(staticLimiter, withSecurityHeaders(withGzip(http.HandlerFunc(serveStatic)))))
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	// LISTEN_ADDR pins the bind address (e.g. 127.0.0.1:8080) and takes precedence over PORT
+	addr := strings.TrimSpace(os.Getenv("LISTEN_ADDR"))
+	if addr == "" {
+		port := os.Getenv("PORT")
+		if port == "" {
+			port = "8080"
+		}
+		addr = ":" + port
 	}
 
-	log.Printf("Starting portal server on :%s (DEMO_MODE=%v)", port, demoMode)
-	if err := http.ListenAndServe(":"+port, nil); err != nil {
-		log.Fatalf("Server error: %v", err)
+	shutdownTimeout = parseDurationEnv("SHUTDOWN_TIMEOUT", shutdownTimeout)
+	readHeaderTimeout = parseDurationEnv("READ_HEADER_TIMEOUT", readHeaderTimeout)
+	readTimeout = parseDurationEnv("READ_TIMEOUT", readTimeout)
+	writeTimeout = parseDurationEnv("WRITE_TIMEOUT", writeTimeout)
+	idleTimeout = parseDurationEnv("IDLE_TIMEOUT", idleTimeout)
+	maxHeaderBytes = parseIntEnv("MAX_HEADER_BYTES", maxHeaderBytes)
+	maxBodyBytes = int64(parseIntEnv("MAX_BODY_BYTES", int(maxBodyBytes)))
+	if writeTimeout <= requestTimeout {
+		log.Printf("WARNING: WRITE_TIMEOUT=%s does not exceed REQUEST_TIMEOUT=%s, slow responses will be cut off", writeTimeout, requestTimeout)
 	}
+
+	tlsCertFile = strings.TrimSpace(os.Getenv("TLS_CERT_FILE"))
+	tlsKeyFile = strings.TrimSpace(os.Getenv("TLS_KEY_FILE"))
+	if (tlsCertFile == "") != (tlsKeyFile == "") {
+		log.Fatalf("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+
+	log.Printf("Starting portal server on %s (DEMO_MODE=%v)", addr, demoMode)
+	serve(&http.Server{Addr: addr, Handler: withRequestID(withRecovery(withBodyLimit(mux)))})
+}
+
+// parseDurationEnv reads a duration from the environment, keeping the fallback when unset or invalid
+func parseDurationEnv(name string, fallback time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Printf("WARNING: Invalid %s %q, using %s", name, value, fallback)
+		return fallback
+	}
+	return d
+}
+
+// parseIntEnv reads a positive integer from the environment, keeping the fallback when unset or invalid
+func parseIntEnv(name string, fallback int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		log.Printf("WARNING: Invalid %s %q, using %d", name, value, fallback)
+		return fallback
+	}
+	return n
 }
 
 // serveStatic serves static files or returns 404
@@ -89,17 +526,31 @@ func serveStatic(w http.ResponseWriter, r *http.Request) {
 
 	// Read file from embedded filesystem
 	content, err := fs.ReadFile(staticFS, path)
+	if err != nil && !hasFileExtension(path) {
+		// Extension-less paths are client-side routes, so hand them to the SPA
+		path = "index.html"
+		content, err = fs.ReadFile(staticFS, path)
+	}
 	if err != nil {
 		http.Error(w, "404 - Page Not Found", http.StatusNotFound)
 		return
 	}
 
+	if writeStaticCacheHeaders(w, r, path) {
+		return
+	}
+
 	// Set content type based on file extension
 	contentType := getContentType(path)
 	w.Header().Set("Content-Type", contentType)
 	w.Write(content)
 }
 
+// hasFileExtension reports whether the last path segment has an extension, marking an asset request
+func hasFileExtension(path string) bool {
+	return strings.LastIndex(path, ".") > strings.LastIndex(path, "/")
+}
+
 // getContentType returns the appropriate content type for a file
 func getContentType(path string) string {
 	switch {
@@ -124,233 +575,816 @@ func getContentType(path string) string {
 	}
 }
 
-// handleApps returns filtered apps based on user groups
+// appsAllowedMethods is the Allow header of /api/apps
+const appsAllowedMethods = "GET, HEAD, OPTIONS"
+
+// handleApps returns filtered apps based on user groups; HEAD runs the same logic
+// without sending the body
 func handleApps(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "GET" {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+	case http.MethodOptions:
+		w.Header().Set("Allow", appsAllowedMethods)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	default:
+		w.Header().Set("Allow", appsAllowedMethods)
 		w.Header().Set("Content-Type", "application/json")
 		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	if maintenanceMode.Load() {
+		w.Header().Set("X-Portal-Maintenance", "true")
+	}
 
+	reqID := requestID(r.Context())
+	user := getUserName(r)
 	userGroups := getUserGroups(r)
-	log.Printf("Apps request: user_groups=%v remote_addr=%s", userGroups, r.RemoteAddr)
+	slog.Info("Apps request", "request_id", reqID, "user", user, "user_groups", userGroups, "remote_addr", r.RemoteAddr)
 
-	var apps []App
-	var err error
+	if r.URL.Query().Get("refresh") == "true" {
+		if !isAdmin(userGroups) {
+			log.Printf("WARNING: Ignoring forced refresh from non-admin request_id=%s user_groups=%v remote_addr=%s", reqID, userGroups, r.RemoteAddr)
+		} else if maintenanceMode.Load() {
+			log.Printf("WARNING: Ignoring forced refresh during maintenance from request_id=%s user_groups=%v remote_addr=%s", reqID, userGroups, r.RemoteAddr)
+		} else {
+			log.Printf("Forced refresh requested by request_id=%s user_groups=%v remote_addr=%s", reqID, userGroups, r.RemoteAddr)
+			if _, err := refreshApps(r.Context()); err != nil {
+				log.Printf("ERROR refreshing apps request_id=%s: %v", reqID, err)
+				if lastFetchedAt().IsZero() {
+					http.Error(w, `{"error":"failed to fetch apps"}`, http.StatusInternalServerError)
+					return
+				}
+			}
+		}
+	}
 
-	if demoMode {
-		apps, err = getDemoApps()
-	} else {
-		apps, err = getK8sApps()
+	apps, err := fetchApps(r.Context())
+	if err != nil {
+		log.Printf("ERROR fetching apps request_id=%s: %v", reqID, err)
+		if isTimeoutError(err) {
+			http.Error(w, `{"error":"kubernetes API timed out"}`, http.StatusGatewayTimeout)
+			return
+		}
+		http.Error(w, `{"error":"failed to fetch apps"}`, http.StatusInternalServerError)
+		return
+	}
+
+	if lastLoadError() != nil && !maintenanceMode.Load() {
+		writeStaleWarning(w)
+	}
+
+	w.Header().Add("Vary", "Accept-Language")
+	filtered := localizeApps(markFavorites(visibleApps(apps, userGroups), user), acceptedLanguages(r))
+	slog.Info("Apps response", "request_id", reqID, "user", user, "user_groups", userGroups, "remote_addr", r.RemoteAddr, "total", len(apps), "filtered", len(filtered))
+	audit.record(r, userGroups, len(filtered), len(apps)-len(filtered))
+
+	if q := strings.TrimSpace(r.URL.Query().Get("q")); q != "" {
+		filtered = searchApps(filtered, q)
+	}
+	// ?category= narrows the list before ?grouped=true buckets it, so the two
+	// combined yield at most one section
+	if category := strings.TrimSpace(r.URL.Query().Get("category")); category != "" {
+		filtered = appsInCategory(filtered, category)
+	}
+	if tag := strings.TrimSpace(r.URL.Query().Get("tag")); tag != "" {
+		filtered = appsWithTag(filtered, tag)
+	}
+
+	var response interface{} = filtered
+	if r.URL.Query().Get("grouped") == "true" {
+		response = groupAppsByCategory(filtered)
+	}
+	if includeUser {
+		response = appsEnvelope{User: user, Apps: response}
+	}
+
+	var body bytes.Buffer
+	if err := writeJSON(&body, r, response); err != nil {
+		log.Printf("ERROR encoding apps response request_id=%s: %v", reqID, err)
+		http.Error(w, `{"error":"failed to encode apps"}`, http.StatusInternalServerError)
+		return
+	}
+
+	// The response depends on the caller's groups, so keep it out of shared caches
+	// and tie the ETag to them
+	etag := appsETag(userGroups, body.Bytes())
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.Header().Set("ETag", "W/"+etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	if r.Method == http.MethodHead {
+		w.Header().Set("Content-Length", strconv.Itoa(body.Len()))
+		return
+	}
+	if _, err := w.Write(body.Bytes()); err != nil {
+		log.Printf("ERROR writing apps response request_id=%s: %v", reqID, err)
+	}
+}
+
+// appsETag hashes the caller's groups, in any order, together with the response body
+func appsETag(groups []string, body []byte) string {
+	sorted := append([]string(nil), groups...)
+	sort.Strings(sorted)
+
+	h := sha256.New()
+	h.Write([]byte(strings.Join(sorted, ",")))
+	h.Write([]byte{0})
+	h.Write(body)
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// handleAppByID returns a single app by its dashboard.home/id, after group filtering.
+// Apps that exist but are hidden from the caller return the same 404 as unknown ids,
+// so the endpoint cannot be used to probe for apps outside the caller's groups; the
+// cost is that a missing-permission problem looks like a typo to the user.
+func handleAppByID(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, "/api/apps/")
+	if id == "" || strings.Contains(id, "/") {
+		http.Error(w, `{"error":"app not found"}`, http.StatusNotFound)
+		return
+	}
+
+	userGroups := getUserGroups(r)
+	log.Printf("App request: id=%s user_groups=%v remote_addr=%s", id, userGroups, r.RemoteAddr)
+
+	apps, err := fetchApps(r.Context())
+	if err != nil {
+		log.Printf("ERROR fetching apps: %v", err)
+		http.Error(w, `{"error":"failed to fetch apps"}`, http.StatusInternalServerError)
+		return
+	}
+
+	for _, app := range localizeApps(markFavorites(visibleApps(apps, userGroups), getUserName(r)), acceptedLanguages(r)) {
+		if app.ID == id {
+			if err := writeJSON(w, r, app); err != nil {
+				log.Printf("ERROR encoding app response: %v", err)
+			}
+			return
+		}
+	}
+
+	http.Error(w, `{"error":"app not found"}`, http.StatusNotFound)
+}
+
+// writeJSON encodes v as the response body, indented when the caller asks for ?pretty=true
+func writeJSON(w io.Writer, r *http.Request, v interface{}) error {
+	enc := json.NewEncoder(w)
+	if r.URL.Query().Get("pretty") == "true" {
+		enc.SetIndent("", "  ")
+	}
+	return enc.Encode(v)
+}
+
+// fetchApps discovers apps and decorates them with background-fetched data
+func fetchApps(ctx context.Context) ([]App, error) {
+	apps, err := discoverApps(ctx)
+	if err != nil {
+		return nil, err
+	}
+	badges.apply(apps)
+	healthChecks.apply(apps)
+	icons.apply(apps)
+	return apps, nil
+}
+
+// handleMaintenance reports maintenance mode and lets admins toggle it at runtime
+func handleMaintenance(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case "GET":
+	case "POST":
+		userGroups := getUserGroups(r)
+		if !isAdmin(userGroups) {
+			http.Error(w, `{"error":"forbidden"}`, http.StatusForbidden)
+			return
+		}
+
+		var body struct {
+			Enabled bool `json:"enabled"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+			return
+		}
+
+		maintenanceMode.Store(body.Enabled)
+		log.Printf("Maintenance mode set to %v by user_groups=%v remote_addr=%s", body.Enabled, userGroups, r.RemoteAddr)
+	default:
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, r, map[string]bool{"maintenance": maintenanceMode.Load()})
+}
+
+// handleGroups returns, for admins, the sorted set of groups referenced by any
+// discovered app, before group filtering
+func handleGroups(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userGroups := getUserGroups(r)
+	if !isAdmin(userGroups) {
+		http.Error(w, `{"error":"forbidden"}`, http.StatusForbidden)
+		return
+	}
+
+	apps, err := fetchApps(r.Context())
+	if err != nil {
+		log.Printf("ERROR fetching apps: %v", err)
+		http.Error(w, `{"error":"failed to fetch apps"}`, http.StatusInternalServerError)
+		return
+	}
+
+	seen := make(map[string]bool)
+	groups := []string{}
+	for _, app := range apps {
+		for _, group := range app.Groups {
+			group = strings.TrimSpace(group)
+			if group == "" || seen[group] {
+				continue
+			}
+			seen[group] = true
+			groups = append(groups, group)
+		}
+	}
+	sort.Strings(groups)
+
+	if err := writeJSON(w, r, groups); err != nil {
+		log.Printf("ERROR encoding groups response: %v", err)
+	}
+}
+
+// handleGroupStats returns, for admins, how many apps each group grants access to
+// across all discovered apps, plus the number of apps open to everyone
+func handleGroupStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
 	}
 
+	userGroups := getUserGroups(r)
+	if !isAdmin(userGroups) {
+		http.Error(w, `{"error":"forbidden"}`, http.StatusForbidden)
+		return
+	}
+
+	apps, err := fetchApps(r.Context())
 	if err != nil {
 		log.Printf("ERROR fetching apps: %v", err)
 		http.Error(w, `{"error":"failed to fetch apps"}`, http.StatusInternalServerError)
 		return
 	}
 
-	filtered := filterAppsByGroups(apps, userGroups)
-	log.Printf("Apps response: total=%d filtered=%d", len(apps), len(filtered))
+	stats := struct {
+		Groups map[string]int `json:"groups"`
+		Public int            `json:"public"`
+	}{Groups: make(map[string]int)}
 
-	if err := json.NewEncoder(w).Encode(filtered); err != nil {
-		log.Printf("ERROR encoding apps response: %v", err)
+	for _, app := range apps {
+		groups := 0
+		seen := make(map[string]bool)
+		for _, group := range app.Groups {
+			group = strings.TrimSpace(group)
+			if group == "" || seen[strings.ToLower(group)] {
+				continue
+			}
+			seen[strings.ToLower(group)] = true
+			stats.Groups[group]++
+			groups++
+		}
+		if groups == 0 {
+			stats.Public++
+		}
+	}
+
+	if err := writeJSON(w, r, stats); err != nil {
+		log.Printf("ERROR encoding group stats response: %v", err)
 	}
 }
 
-// handleHealth is a liveness/readiness probe endpoint
-func handleHealth(w http.ResponseWriter, r *http.Request) {
+// isAdmin reports whether any of the user's groups is a configured admin group
+func isAdmin(userGroups []string) bool {
+	for _, adminGroup := range adminGroups {
+		for _, userGroup := range userGroups {
+			if strings.EqualFold(adminGroup, strings.TrimSpace(userGroup)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// splitGroups splits a comma-separated group list, trimming whitespace and dropping empties
+func splitGroups(value string) []string {
+	return parseListAnnotation(value)
+}
+
+// handleLivez is the liveness probe: it succeeds whenever the process can serve requests
+func handleLivez(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"status": "healthy"})
 }
 
-// getUserGroups extracts user groups from X-Auth-Request-Groups header
+// handleReadyz is the readiness probe. In Kubernetes mode it fails until the informer
+// caches of at least one cluster have synced, and when every synced cluster's informers
+// went stale. It also fails while the most recent app discovery failed.
+func handleReadyz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	reason := ""
+	if !demoMode && !clustersSynced() {
+		reason = "ingress cache not synced"
+	} else if !demoMode && !clustersFresh() {
+		reason = fmt.Sprintf("informer cache stale: no API server contact for over %s", informerStaleAfter)
+	} else if err := lastLoadError(); err != nil {
+		reason = "last ingress fetch failed: " + err.Error()
+	}
+
+	if reason != "" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"status": "unavailable", "reason": reason})
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "ready"})
+}
+
+// appsEnvelope is the /api/apps response shape when INCLUDE_USER=true
+type appsEnvelope struct {
+	User string      `json:"user"`
+	Apps interface{} `json:"apps"`
+}
+
+// getUserName returns the username from the configured user header (USER_HEADER)
+func getUserName(r *http.Request) string {
+	return strings.TrimSpace(r.Header.Get(userHeaderName))
+}
+
+// getUserGroups extracts user groups from the configured groups header (GROUPS_HEADER),
+// see parseGroupsHeader
 func getUserGroups(r *http.Request) []string {
+	reqID := requestID(r.Context())
 	if debugMode {
-		log.Printf("DEBUG: All request headers:")
+		log.Printf("DEBUG: All request headers request_id=%s:", reqID)
 		for key, values := range r.Header {
 			for _, value := range values {
-				log.Printf("  %s: %s", key, value)
+				log.Printf("DEBUG:   %s: %s", key, value)
 			}
 		}
 	}
 
 	if demoMode {
-		log.Printf("DEBUG: Using demo mode groups")
-		return demoGroups
+		log.Printf("DEBUG: Using demo mode groups request_id=%s", reqID)
+		return groupHierarchy.expand(demoConfig.groups())
+	}
+
+	if authMode == authModeJWT {
+		groups := groupsFromJWT(r)
+		if debugMode {
+			log.Printf("DEBUG: Groups from JWT claim %q request_id=%s: %v", groupsClaim, reqID, groups)
+		}
+		return groupHierarchy.expand(groups)
 	}
 
-	groupsHeader := r.Header.Get("X-Forwarded-Groups")
+	groupsHeader := r.Header.Get(groupsHeaderName)
 	if debugMode {
-		log.Printf("DEBUG: X-Forwarded-Groups header value: %q", groupsHeader)
+		log.Printf("DEBUG: %s header value request_id=%s: %q", groupsHeaderName, reqID, groupsHeader)
 	}
 
 	if groupsHeader == "" {
-		log.Printf("WARNING: No groups found in x-auth-request-groups header")
+		log.Printf("WARNING: No groups found in %s header request_id=%s", groupsHeaderName, reqID)
 		return []string{}
 	}
 
-	groups := strings.Split(groupsHeader, ",")
-	for i := range groups {
-		groups[i] = strings.TrimSpace(groups[i])
-	}
-	
-	log.Printf("Parsed groups from header: %v", groups)
-	return groups
+	groups := parseGroupsHeader(groupsHeader)
+
+	log.Printf("Parsed groups from header request_id=%s: %v", reqID, groups)
+	return groupHierarchy.expand(groups)
 }
 
-// loadDemoGroups loads group configuration from YAML file for demo mode
-func loadDemoGroups() {
-	data, err := os.ReadFile("/etc/dashboard/config.yaml")
-	if err != nil {
-		data, err = os.ReadFile("config.yaml")
-		if err != nil {
-			log.Printf("WARNING: Failed to load demo groups config: %v", err)
-			return
+// parseGroupsHeader splits a groups header on GROUPS_DELIMITER, or decodes it as a JSON
+// array of strings when it starts with "[". Entries are trimmed and empty ones dropped.
+func parseGroupsHeader(value string) []string {
+	var raw []string
+	switch trimmed := strings.TrimSpace(value); {
+	case strings.HasPrefix(trimmed, "["):
+		if err := json.Unmarshal([]byte(trimmed), &raw); err != nil {
+			log.Printf("WARNING: Ignoring malformed JSON in %s header: %v", groupsHeaderName, err)
+			return []string{}
 		}
+	case strings.TrimSpace(groupsDelimiter) == "":
+		raw = strings.Fields(trimmed)
+	default:
+		raw = strings.Split(trimmed, groupsDelimiter)
 	}
 
-	var config Config
-	if err := yaml.Unmarshal(data, &config); err != nil {
-		log.Printf("WARNING: Failed to parse demo groups: %v", err)
-		return
+	groups := []string{}
+	for _, group := range raw {
+		if group = strings.TrimSpace(group); group != "" {
+			groups = append(groups, group)
+		}
 	}
+	return groups
+}
 
-	if config.Groups != "" {
-		demoGroups = strings.Split(config.Groups, ",")
-		for i := range demoGroups {
-			demoGroups[i] = strings.TrimSpace(demoGroups[i])
-		}
-		log.Printf("Demo mode enabled with groups: %v", demoGroups)
+// namespaceDefaults holds per-namespace fallbacks for app fields
+var namespaceDefaults = &namespaceDefaultsStore{}
+
+// namespaceDefaultsStore guards the namespace defaults, which demo config reloads
+// replace while requests read them
+type namespaceDefaultsStore struct {
+	mu       sync.RWMutex
+	defaults map[string]NamespaceDefault
+	// fromFile is set once NAMESPACE_DEFAULTS_FILE loaded; the demo config then stops applying
+	fromFile bool
+}
+
+// get returns the defaults for namespace, or the zero value when it has none
+func (s *namespaceDefaultsStore) get(namespace string) NamespaceDefault {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.defaults[namespace]
+}
+
+// setFromFile installs the defaults from NAMESPACE_DEFAULTS_FILE
+func (s *namespaceDefaultsStore) setFromFile(defaults map[string]NamespaceDefault) {
+	s.mu.Lock()
+	s.defaults = defaults
+	s.fromFile = true
+	s.mu.Unlock()
+}
+
+// setFromDemo installs the demo config's defaults on every load, unless
+// NAMESPACE_DEFAULTS_FILE supplied them
+func (s *namespaceDefaultsStore) setFromDemo(defaults map[string]NamespaceDefault) {
+	s.mu.Lock()
+	if !s.fromFile {
+		s.defaults = defaults
 	}
+	s.mu.Unlock()
 }
 
-// getDemoApps loads apps from local config.yaml for development/testing
-func getDemoApps() ([]App, error) {
-	data, err := os.ReadFile("/etc/dashboard/config.yaml")
+// loadNamespaceDefaults reads the namespaceDefaults section of a config-format YAML file
+func loadNamespaceDefaults(path string) error {
+	data, err := os.ReadFile(path)
 	if err != nil {
-		data, err = os.ReadFile("config.yaml")
-		if err != nil {
-			return nil, err
-		}
+		return err
 	}
 
 	var config Config
 	if err := yaml.Unmarshal(data, &config); err != nil {
-		return nil, err
+		return err
 	}
 
-	log.Printf("Demo mode: loading %d ingress configs from file", len(config.Ingresses))
+	namespaceDefaults.setFromFile(config.NamespaceDefaults)
+	log.Printf("Loaded defaults for %d namespaces from %s", len(config.NamespaceDefaults), path)
+	return nil
+}
 
-	var apps []App
-	for _, ing := range config.Ingresses {
-		if ing.Annotations["dashboard.home/enabled"] != "true" {
-			continue
-		}
+// appFromAnnotations maps the dashboard annotations shared by every discovery source
+// onto an App; the caller fills in the URL and icon fallback. It takes appAnnotations
+// rather than the raw map so the per-source prefix and namespace defaults apply, and
+// it does not decide whether the object is an app: skipReason does that first, and
+// appsFromRoutes, which both Kubernetes and demo discovery call, adds the URLs.
+func appFromAnnotations(annotations appAnnotations, namespace string) App {
+	return App{
+		ID:              annotations.get("id"),
+		Title:           annotations.get("title"),
+		Icon:            annotations.get("icon"),
+		Description:     annotations.get("description"),
+		DescriptionHTML: renderDescription(annotations.get("description")),
+		Localized:       annotations.getLocalized(),
+		NewTab:          annotations.getBool("new-tab"),
+		Category:        resolveCategory(annotations, namespace),
+		Tags:            annotations.getList("tags"),
+		BadgeURL:        annotations.getURL("badge-url"),
+		BadgePath:       annotations.get("badge-path"),
+		HealthCheckPath: annotations.get("healthcheck-path"),
+		Groups:          annotations.getList("groups"),
+		Match:           annotations.getMatch(),
+		DenyGroups:      annotations.getList("deny-groups"),
+		Weight:          annotations.getInt("weight"),
+	}
+}
 
-		app := App{
-			Title:       ing.Annotations["dashboard.home/title"],
-			Icon:        ing.Annotations["dashboard.home/icon"],
-			Description: ing.Annotations["dashboard.home/description"],
-			URL:         "https://example.com",
-		}
+// derivedAppID is the ID of an app without an id annotation: the first 12 hex digits
+// of sha256("<namespace>/<name>"), with the title standing in for the name of demo
+// entries. It only changes when the object is renamed or moved, so clients can key
+// pins and favorites on it.
+func derivedAppID(namespace, name string) string {
+	sum := sha256.Sum256([]byte(namespace + "/" + name))
+	return hex.EncodeToString(sum[:6])
+}
 
-		if groups := ing.Annotations["dashboard.home/groups"]; groups != "" {
-			app.Groups = strings.Split(groups, ",")
-		}
+// appAnnotations reads dashboard annotations under the prefix configured for their source
+type appAnnotations struct {
+	values map[string]string
+	prefix string
+	// defaults are the namespace's default annotations, keyed without the prefix
+	defaults map[string]string
+}
+
+// undefaultedAnnotations must be set on the resource itself: a namespace default
+// can't turn every object into an app or give them all the same id
+var undefaultedAnnotations = map[string]bool{"enabled": true, "id": true}
+
+// annotationsFor wraps a resource's annotations with the prefix used by the given source
+// and the namespace's default annotations
+func annotationsFor(source, namespace string, values map[string]string) appAnnotations {
+	prefix, ok := annotationPrefixes[source]
+	if !ok {
+		prefix = defaultAnnotationPrefix
+	}
+	return appAnnotations{values: values, prefix: prefix, defaults: namespaceDefaults.get(namespace).Annotations}
+}
 
-		apps = append(apps, app)
+// lookup returns the raw annotation for key under the source prefix, falling back to
+// the namespace default. Reading from nil maps is safe and yields "".
+func (a appAnnotations) lookup(key string) string {
+	if value, ok := a.values[a.prefix+key]; ok || undefaultedAnnotations[key] {
+		return value
 	}
+	return a.defaults[key]
+}
 
-	log.Printf("Demo mode: %d apps enabled", len(apps))
-	return apps, nil
+// get returns the trimmed annotation for key, e.g. get("title"), see lookup
+func (a appAnnotations) get(key string) string {
+	return strings.TrimSpace(a.lookup(key))
+}
+
+// getBool parses a boolean annotation, see parseBoolAnnotation
+func (a appAnnotations) getBool(key string) bool {
+	return parseBoolAnnotation(a.lookup(key))
 }
 
-// getK8sApps queries Kubernetes API for Ingress resources with dashboard annotations
-func getK8sApps() ([]App, error) {
-	config, err := rest.InClusterConfig()
+// getList parses a comma-separated annotation, see parseListAnnotation
+func (a appAnnotations) getList(key string) []string {
+	return parseListAnnotation(a.lookup(key))
+}
+
+// getInt parses an integer annotation, returning nil when it is absent or invalid
+func (a appAnnotations) getInt(key string) *int {
+	raw := a.get(key)
+	if raw == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(raw)
 	if err != nil {
-		log.Printf("ERROR: Failed to get in-cluster config: %v", err)
-		return nil, err
+		log.Printf("WARNING: Ignoring non-integer annotation %s%s=%q", a.prefix, key, raw)
+		return nil
 	}
+	return &n
+}
 
-	clientset, err := kubernetes.NewForConfig(config)
-	if err != nil {
-		log.Printf("ERROR: Failed to create Kubernetes clientset: %v", err)
-		return nil, err
+// getMatch returns the dashboard.home/match mode, "all" or the default "any"
+func (a appAnnotations) getMatch() string {
+	switch raw := strings.ToLower(a.get("match")); raw {
+	case "", matchAny:
+		return matchAny
+	case matchAll:
+		return matchAll
+	default:
+		log.Printf("WARNING: Ignoring unknown annotation %smatch=%q, using %q", a.prefix, raw, matchAny)
+		return matchAny
 	}
+}
 
-	ingresses, err := clientset.NetworkingV1().Ingresses("").List(context.Background(), metav1.ListOptions{})
-	if err != nil {
-		log.Printf("ERROR: Failed to list ingresses: %v", err)
-		return nil, err
+// getScheme returns the dashboard.home/scheme override in lower case, or "" when it is
+// absent or not an allowed scheme
+func (a appAnnotations) getScheme() string {
+	raw := strings.ToLower(a.get("scheme"))
+	if raw == "" {
+		return ""
+	}
+	if !schemeAllowed(raw) {
+		log.Printf("WARNING: Ignoring annotation %sscheme=%q: scheme not allowed", a.prefix, raw)
+		return ""
 	}
+	return raw
+}
 
-	log.Printf("Kubernetes mode: found %d total ingresses", len(ingresses.Items))
+// getPort returns the dashboard.home/port override, or "" when it is absent or not a
+// port number between 1 and 65535
+func (a appAnnotations) getPort() string {
+	raw := a.get("port")
+	if raw == "" {
+		return ""
+	}
+	if port, err := strconv.Atoi(raw); err != nil || port < 1 || port > 65535 {
+		log.Printf("WARNING: Ignoring annotation %sport=%q: not a valid port", a.prefix, raw)
+		return ""
+	}
+	return raw
+}
 
-	var apps []App
-	for _, ing := range ingresses.Items {
-		if ing.Annotations["dashboard.home/enabled"] != "true" {
-			continue
-		}
+// parseBoolAnnotation accepts the strconv.ParseBool spellings ("true", "1", "TRUE", ...)
+// plus "yes" and "on", case-insensitively and ignoring surrounding whitespace; anything
+// else, including "", is false
+func parseBoolAnnotation(value string) bool {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "yes" || value == "on" {
+		return true
+	}
+	b, err := strconv.ParseBool(value)
+	return err == nil && b
+}
 
-		app := App{
-			Title:       ing.Annotations["dashboard.home/title"],
-			Icon:        ing.Annotations["dashboard.home/icon"],
-			Description: ing.Annotations["dashboard.home/description"],
-			URL:         getIngressURL(&ing),
+// parseListAnnotation splits a comma-separated annotation, trimming entries and
+// dropping empty ones; it returns nil when no entries remain
+func parseListAnnotation(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
 		}
+	}
+	return items
+}
 
-		if groups := ing.Annotations["dashboard.home/groups"]; groups != "" {
-			app.Groups = strings.Split(groups, ",")
+// getURL returns a URL-bearing annotation, dropping it with a warning when its scheme
+// is not in ALLOWED_URL_SCHEMES so javascript: or data: links never reach the frontend
+func (a appAnnotations) getURL(key string) string {
+	raw := strings.TrimSpace(a.get(key))
+	if raw == "" {
+		return ""
+	}
+	if err := checkURLScheme(raw); err != nil {
+		log.Printf("WARNING: Ignoring annotation %s%s=%q: %v", a.prefix, key, raw, err)
+		return ""
+	}
+	return raw
+}
+
+// parseAnnotationPrefixes parses a "source=prefix,..." list into per-source prefixes
+func parseAnnotationPrefixes(value string) map[string]string {
+	prefixes := make(map[string]string)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		source, prefix, ok := strings.Cut(entry, "=")
+		source = strings.ToLower(strings.TrimSpace(source))
+		prefix = strings.TrimSpace(prefix)
+		if !ok || source == "" || prefix == "" {
+			log.Printf("WARNING: Ignoring invalid annotation prefix entry %q", entry)
+			continue
+		}
+		if !strings.HasSuffix(prefix, "/") {
+			prefix += "/"
 		}
+		prefixes[source] = prefix
+	}
+	return prefixes
+}
 
-		apps = append(apps, app)
-		log.Printf("Added app: title=%s namespace=%s groups=%v", app.Title, ing.Namespace, app.Groups)
+// visibleApps returns the apps a user may see: everything for ADMIN_GROUPS members,
+// otherwise the group-filtered list without cluster topology
+func visibleApps(apps []App, userGroups []string) []App {
+	if isAdmin(userGroups) {
+		if apps == nil {
+			return []App{}
+		}
+		return apps
 	}
+	return hideTopology(filterAppsByGroups(apps, userGroups))
+}
 
-	log.Printf("Kubernetes mode: %d apps enabled", len(apps))
-	return apps, nil
+// searchApps keeps the apps whose title or description contains q, ignoring case
+func searchApps(apps []App, q string) []App {
+	q = strings.ToLower(q)
+	matched := []App{}
+	for _, app := range apps {
+		if strings.Contains(strings.ToLower(app.Title), q) || strings.Contains(strings.ToLower(app.Description), q) {
+			matched = append(matched, app)
+		}
+	}
+	return matched
 }
 
-// getIngressURL constructs the URL from ingress configuration
-func getIngressURL(ing *v1.Ingress) string {
-	if len(ing.Spec.Rules) > 0 {
-		host := ing.Spec.Rules[0].Host
-		if len(ing.Spec.TLS) > 0 {
-			return "https://" + host
+// appsWithTag keeps the apps carrying tag, ignoring case
+func appsWithTag(apps []App, tag string) []App {
+	matched := []App{}
+	for _, app := range apps {
+		if hasGroup(app.Tags, tag) {
+			matched = append(matched, app)
 		}
-		return "http://" + host
 	}
-	return ""
+	return matched
 }
 
-// filterAppsByGroups filters apps based on user's group membership
-func filterAppsByGroups(apps []App, userGroups []string) []App {
-	if len(userGroups) == 0 {
+// hideTopology returns a copy of apps without their namespace and source, unless
+// debug logging is on
+func hideTopology(apps []App) []App {
+	if debugMode || apps == nil {
 		return apps
 	}
+	hidden := make([]App, len(apps))
+	for i, app := range apps {
+		app.Namespace, app.Source = "", ""
+		hidden[i] = app
+	}
+	return hidden
+}
+
+// filterAppsByGroups filters apps based on user's group membership. A user without
+// groups sees everything except apps for authenticated users only (dashboard.home/groups
+// "*"), or only public apps when DEFAULT_DENY is set. The result is never nil, so an
+// empty list encodes as [] rather than null.
+func filterAppsByGroups(apps []App, userGroups []string) []App {
+	if len(userGroups) == 0 && !defaultDeny {
+		filtered := []App{}
+		for _, app := range apps {
+			if !hasGroup(app.Groups, anyGroup) {
+				filtered = append(filtered, app)
+			}
+		}
+		return filtered
+	}
 
-	var filtered []App
+	filtered := []App{}
 	for _, app := range apps {
-		if len(app.Groups) == 0 {
+		if appMatchesGroups(app, userGroups) {
 			filtered = append(filtered, app)
-			continue
 		}
+	}
 
-		for _, appGroup := range app.Groups {
-			for _, userGroup := range userGroups {
-				if strings.EqualFold(strings.TrimSpace(appGroup), strings.TrimSpace(userGroup)) {
-					filtered = append(filtered, app)
-					goto next
-				}
-			}
+	return filtered
+}
+
+// appMatchesGroups reports whether the user's groups grant access to app: any one of
+// its groups by default, every one of them when its match mode is "all", see
+// matchesGroup. The group "*" is held by every user with at least one group. Deny wins over allow:
+// membership in any dashboard.home/deny-groups group hides the app.
+func appMatchesGroups(app App, userGroups []string) bool {
+	for _, denied := range app.DenyGroups {
+		if matchesGroup(userGroups, denied) {
+			return false
 		}
-	next:
 	}
 
-	return filtered
-}
\ No newline at end of file
+	if len(app.Groups) == 0 {
+		return true
+	}
+
+	for _, appGroup := range app.Groups {
+		member := matchesGroup(userGroups, appGroup) || (appGroup == anyGroup && len(userGroups) > 0)
+		if member && app.Match != matchAll {
+			return true
+		}
+		if !member && app.Match == matchAll {
+			return false
+		}
+	}
+	return app.Match == matchAll
+}
+
+// matchesGroup reports whether any of the user's groups matches an app group entry.
+// Entries containing "*" other than anyGroup are path.Match globs, so "team/media/*"
+// matches "team/media/admin" but not "team/media/a/b"; others must match exactly.
+// Both ignore case.
+func matchesGroup(userGroups []string, entry string) bool {
+	entry = strings.TrimSpace(entry)
+	if entry == anyGroup || !strings.Contains(entry, "*") {
+		return hasGroup(userGroups, entry)
+	}
+
+	pattern := strings.ToLower(entry)
+	for _, g := range userGroups {
+		if ok, err := path.Match(pattern, strings.ToLower(strings.TrimSpace(g))); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
+// hasGroup reports whether group is among groups, ignoring case and surrounding whitespace
+func hasGroup(groups []string, group string) bool {
+	for _, g := range groups {
+		if strings.EqualFold(strings.TrimSpace(g), strings.TrimSpace(group)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseBoolAnnotation(t *testing.T) {
+	tests := []struct {
+		value string
+		want  bool
+	}{
+		{"", false},
+		{"true", true},
+		{"TRUE", true},
+		{" True ", true},
+		{"1", true},
+		{"yes", true},
+		{" yes ", true},
+		{"ON", true},
+		{"false", false},
+		{"no", false},
+		{"0", false},
+		{"enabled", false},
+		{"  ", false},
+	}
+	for _, tt := range tests {
+		if got := parseBoolAnnotation(tt.value); got != tt.want {
+			t.Errorf("parseBoolAnnotation(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParseListAnnotation(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{"", nil},
+		{"  ", nil},
+		{",", nil},
+		{"a", []string{"a"}},
+		{"a,b", []string{"a", "b"}},
+		{" a , b ", []string{"a", "b"}},
+		{"a,,b", []string{"a", "b"}},
+		{"a, ,b,", []string{"a", "b"}},
+		{"Admin,admin", []string{"Admin", "admin"}},
+	}
+	for _, tt := range tests {
+		if got := parseListAnnotation(tt.value); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseListAnnotation(%q) = %#v, want %#v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestAnnotationGettersWithNilMap(t *testing.T) {
+	annotations := annotationsFor(sourceIngress, "", nil)
+	if got := annotations.get("title"); got != "" {
+		t.Errorf("get() = %q, want empty", got)
+	}
+	if annotations.getBool("enabled") {
+		t.Error("getBool() = true, want false")
+	}
+	if got := annotations.getList("groups"); got != nil {
+		t.Errorf("getList() = %#v, want nil", got)
+	}
+}
+
+func TestAnnotationGettersTrimAndCase(t *testing.T) {
+	annotations := annotationsFor(sourceIngress, "", map[string]string{
+		"dashboard.home/enabled": " TRUE ",
+		"dashboard.home/title":   "  Grafana ",
+		"dashboard.home/groups":  "admin,, users ,",
+	})
+	if !annotations.getBool("enabled") {
+		t.Error("getBool(enabled) = false, want true")
+	}
+	if got := annotations.get("title"); got != "Grafana" {
+		t.Errorf("get(title) = %q, want %q", got, "Grafana")
+	}
+	if got, want := annotations.getList("groups"), []string{"admin", "users"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("getList(groups) = %#v, want %#v", got, want)
+	}
+}
+
+// seedApps serves apps from the discovery cache for the rest of the test
+func seedApps(t *testing.T, apps []App) {
+	t.Helper()
+	lastGood.Lock()
+	lastGood.apps = apps
+	lastGood.fetchedAt = time.Now()
+	lastGood.invalidated = false
+	lastGood.err = nil
+	lastGood.Unlock()
+	t.Cleanup(func() {
+		lastGood.Lock()
+		lastGood.apps = nil
+		lastGood.fetchedAt = time.Time{}
+		lastGood.Unlock()
+	})
+}
+
+func TestHandleAppsNoMatchIsEmptyArray(t *testing.T) {
+	seedApps(t, []App{
+		{ID: "grafana", Title: "Grafana", Groups: []string{"admin"}, Category: "Monitoring"},
+		{ID: "argocd", Title: "ArgoCD", Groups: []string{"ops"}},
+	})
+
+	tests := []struct {
+		name        string
+		query       string
+		includeUser bool
+		want        string
+	}{
+		{name: "list", want: `[]`},
+		{name: "search without match", query: "?q=nothing", want: `[]`},
+		{name: "grouped", query: "?grouped=true", want: `[]`},
+		{name: "envelope", includeUser: true, want: `{"user":"alice","apps":[]}`},
+		{name: "grouped envelope", query: "?grouped=true", includeUser: true, want: `{"user":"alice","apps":[]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			includeUser = tt.includeUser
+			defer func() { includeUser = false }()
+
+			req := httptest.NewRequest(http.MethodGet, "/api/apps"+tt.query, nil)
+			req.Header.Set(groupsHeaderName, "nobody")
+			req.Header.Set(userHeaderName, "alice")
+			rec := httptest.NewRecorder()
+			handleApps(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+			}
+			if got := strings.TrimSpace(rec.Body.String()); got != tt.want {
+				t.Errorf("body = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetOnlyHandlersRejectOtherMethods(t *testing.T) {
+	handlers := map[string]http.HandlerFunc{
+		"/api/apps/grafana": handleAppByID,
+		"/api/groups":       handleGroups,
+		"/api/stats/groups": handleGroupStats,
+	}
+	for path, handler := range handlers {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodPost, path, nil))
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("POST %s: status %d, want %d", path, w.Code, http.StatusMethodNotAllowed)
+		}
+		if got := w.Header().Get("Allow"); got != http.MethodGet {
+			t.Errorf("POST %s: Allow = %q, want %q", path, got, http.MethodGet)
+		}
+	}
+}
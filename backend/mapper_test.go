@@ -0,0 +1,362 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func intPtr(n int) *int { return &n }
+
+func TestAppFromAnnotations(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		namespace   string
+		defaults    map[string]NamespaceDefault
+		check       func(t *testing.T, app App)
+	}{
+		{
+			name:        "title and description",
+			annotations: map[string]string{"dashboard.home/title": " Grafana ", "dashboard.home/description": "Dashboards"},
+			check: func(t *testing.T, app App) {
+				if app.Title != "Grafana" || app.Description != "Dashboards" {
+					t.Errorf("title, description = %q, %q", app.Title, app.Description)
+				}
+			},
+		},
+		{
+			name:        "icon",
+			annotations: map[string]string{"dashboard.home/icon": "https://example.com/icon.png"},
+			check: func(t *testing.T, app App) {
+				if app.Icon != "https://example.com/icon.png" {
+					t.Errorf("icon = %q", app.Icon)
+				}
+			},
+		},
+		{
+			name:        "category",
+			annotations: map[string]string{"dashboard.home/category": "Media"},
+			check: func(t *testing.T, app App) {
+				if app.Category != "Media" {
+					t.Errorf("category = %q", app.Category)
+				}
+			},
+		},
+		{
+			name:        "tags",
+			annotations: map[string]string{"dashboard.home/tags": "media, ,video"},
+			check: func(t *testing.T, app App) {
+				if want := []string{"media", "video"}; !reflect.DeepEqual(app.Tags, want) {
+					t.Errorf("tags = %#v, want %#v", app.Tags, want)
+				}
+			},
+		},
+		{
+			name:        "weight",
+			annotations: map[string]string{"dashboard.home/weight": "10"},
+			check: func(t *testing.T, app App) {
+				if app.Weight == nil || *app.Weight != 10 {
+					t.Errorf("weight = %v, want 10", app.Weight)
+				}
+			},
+		},
+		{
+			name:        "invalid weight",
+			annotations: map[string]string{"dashboard.home/weight": "heavy"},
+			check: func(t *testing.T, app App) {
+				if app.Weight != nil {
+					t.Errorf("weight = %v, want nil", *app.Weight)
+				}
+			},
+		},
+		{
+			name: "groups",
+			annotations: map[string]string{
+				"dashboard.home/groups":      "admin, users",
+				"dashboard.home/deny-groups": "guests",
+				"dashboard.home/match":       "ALL",
+			},
+			check: func(t *testing.T, app App) {
+				if want := []string{"admin", "users"}; !reflect.DeepEqual(app.Groups, want) {
+					t.Errorf("groups = %#v, want %#v", app.Groups, want)
+				}
+				if want := []string{"guests"}; !reflect.DeepEqual(app.DenyGroups, want) {
+					t.Errorf("deny groups = %#v, want %#v", app.DenyGroups, want)
+				}
+				if app.Match != matchAll {
+					t.Errorf("match = %q, want %q", app.Match, matchAll)
+				}
+			},
+		},
+		{
+			name:        "id",
+			annotations: map[string]string{"dashboard.home/id": "grafana"},
+			check: func(t *testing.T, app App) {
+				if app.ID != "grafana" {
+					t.Errorf("id = %q", app.ID)
+				}
+			},
+		},
+		{
+			name:        "new tab",
+			annotations: map[string]string{"dashboard.home/new-tab": "yes"},
+			check: func(t *testing.T, app App) {
+				if !app.NewTab {
+					t.Error("newTab = false, want true")
+				}
+			},
+		},
+		{
+			name:        "namespace defaults fill missing annotations",
+			annotations: map[string]string{"dashboard.home/title": "Sonarr"},
+			namespace:   "media",
+			defaults: map[string]NamespaceDefault{"media": {Annotations: map[string]string{
+				"groups": "family", "category": "Media", "id": "shared",
+			}}},
+			check: func(t *testing.T, app App) {
+				if want := []string{"family"}; !reflect.DeepEqual(app.Groups, want) {
+					t.Errorf("groups = %#v, want %#v", app.Groups, want)
+				}
+				if app.Category != "Media" {
+					t.Errorf("category = %q, want Media", app.Category)
+				}
+				if app.ID != "" {
+					t.Errorf("id = %q, want id never defaulted", app.ID)
+				}
+			},
+		},
+		{
+			name:        "annotations win over namespace defaults",
+			annotations: map[string]string{"dashboard.home/groups": "admin", "dashboard.home/category": ""},
+			namespace:   "media",
+			defaults: map[string]NamespaceDefault{"media": {Annotations: map[string]string{
+				"groups": "family", "category": "Media",
+			}}},
+			check: func(t *testing.T, app App) {
+				if want := []string{"admin"}; !reflect.DeepEqual(app.Groups, want) {
+					t.Errorf("groups = %#v, want %#v", app.Groups, want)
+				}
+				if app.Category != "" {
+					t.Errorf("category = %q, want the empty annotation to win", app.Category)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setNamespaceDefaults(t, tt.defaults)
+			tt.check(t, appFromAnnotations(annotationsFor(sourceIngress, tt.namespace, tt.annotations), tt.namespace))
+		})
+	}
+}
+
+func TestSkipReason(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		enabled     enabledApps
+		defaults    map[string]NamespaceDefault
+		skipped     bool
+	}{
+		{name: "enabled", annotations: map[string]string{"dashboard.home/enabled": "true"}},
+		{name: "not annotated", annotations: map[string]string{}, skipped: true},
+		{name: "enabled false", annotations: map[string]string{"dashboard.home/enabled": "false"}, skipped: true},
+		{name: "enabled by ConfigMap", annotations: map[string]string{}, enabled: enabledApps{"ns/app": true}},
+		{name: "hidden", annotations: map[string]string{"dashboard.home/enabled": "true", "dashboard.home/hidden": "true"}, skipped: true},
+		{
+			name:        "enabled is never defaulted",
+			annotations: map[string]string{},
+			defaults:    map[string]NamespaceDefault{"ns": {Annotations: map[string]string{"enabled": "true"}}},
+			skipped:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setNamespaceDefaults(t, tt.defaults)
+			reason := skipReason(annotationsFor(sourceIngress, "ns", tt.annotations), tt.enabled, "ns", "app")
+			if (reason != "") != tt.skipped {
+				t.Errorf("skipReason() = %q, want skipped = %v", reason, tt.skipped)
+			}
+		})
+	}
+}
+
+func TestDemoHost(t *testing.T) {
+	tests := []struct{ host, title, want string }{
+		{"grafana.lan", "Grafana", "grafana.lan"},
+		{"", "Home Assistant", "home-assistant.example.com"},
+		{"", "", "example.com"},
+	}
+	for _, tt := range tests {
+		if got := demoHost(tt.host, tt.title); got != tt.want {
+			t.Errorf("demoHost(%q, %q) = %q, want %q", tt.host, tt.title, got, tt.want)
+		}
+	}
+}
+
+// TestDemoAndKubernetesAppsMatch feeds the same annotations through demo discovery
+// and the Ingress informer path and expects the same App
+func TestDemoAndKubernetesAppsMatch(t *testing.T) {
+	setNamespaceDefaults(t, map[string]NamespaceDefault{"monitoring": {Annotations: map[string]string{"category": "Observability"}}})
+	annotations := map[string]string{
+		"dashboard.home/enabled":     "true",
+		"dashboard.home/id":          "grafana",
+		"dashboard.home/title":       "Grafana",
+		"dashboard.home/description": "Dashboards",
+		"dashboard.home/icon":        "https://grafana.example.com/icon.png",
+		"dashboard.home/tags":        "metrics",
+		"dashboard.home/weight":      "5",
+		"dashboard.home/groups":      "admin",
+		"dashboard.home/host":        "grafana.example.com",
+	}
+
+	config := "ingresses:\n- namespace: monitoring\n  annotations:\n"
+	for key, value := range annotations {
+		config += "    " + key + ": \"" + value + "\"\n"
+	}
+	useDemoConfig(t)(config)
+
+	demoApps, err := getDemoApps()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ing := &v1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: "grafana", Namespace: "monitoring", Annotations: annotations},
+		Spec: v1.IngressSpec{
+			TLS:   []v1.IngressTLS{{Hosts: []string{"grafana.example.com"}}},
+			Rules: []v1.IngressRule{{Host: "grafana.example.com"}},
+		},
+	}
+	clientset := fake.NewSimpleClientset(ing)
+	cluster := &kubeCluster{clientset: clientset, ingressVersion: "v1"}
+	if err := cluster.startInformers(clientset, "v1", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 50 && !cluster.watchers.synced.Load(); i++ {
+		time.Sleep(20 * time.Millisecond)
+	}
+	k8sApps, _, err := cluster.apps(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(demoApps) != 1 || len(k8sApps) != 1 {
+		t.Fatalf("got %d demo and %d Kubernetes apps, want 1 each", len(demoApps), len(k8sApps))
+	}
+	demoApp, k8sApp := demoApps[0], k8sApps[0]
+	// Only the Kubernetes path knows the source object's name
+	if k8sApp.Source != "grafana" {
+		t.Errorf("source = %q, want grafana", k8sApp.Source)
+	}
+	k8sApp.Source = ""
+
+	want := App{
+		ID:          "grafana",
+		Title:       "Grafana",
+		Icon:        "https://grafana.example.com/icon.png",
+		URL:         "https://grafana.example.com",
+		Groups:      []string{"admin"},
+		Match:       matchAny,
+		Description: "Dashboards",
+		Category:    "Observability",
+		Tags:        []string{"metrics"},
+		Weight:      intPtr(5),
+		Namespace:   "monitoring",
+	}
+	if !reflect.DeepEqual(demoApp, want) {
+		t.Errorf("demo app = %+v, want %+v", demoApp, want)
+	}
+	if !reflect.DeepEqual(k8sApp, want) {
+		t.Errorf("Kubernetes app = %+v, want %+v", k8sApp, want)
+	}
+}
+
+// setNamespaceDefaults swaps in namespace defaults for the rest of the test, as if
+// loaded from NAMESPACE_DEFAULTS_FILE so demo config loads keep them
+func setNamespaceDefaults(t *testing.T, defaults map[string]NamespaceDefault) {
+	t.Helper()
+	saved := namespaceDefaults
+	namespaceDefaults = &namespaceDefaultsStore{defaults: defaults, fromFile: true}
+	t.Cleanup(func() { namespaceDefaults = saved })
+}
+
+// useDemoConfig points demo mode at a fresh config file for the rest of the test and
+// returns a function that rewrites it
+func useDemoConfig(t *testing.T) func(config string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	savedPaths, savedStore := demoConfigPaths, demoConfig
+	demoConfigPaths, demoConfig = []string{path}, &demoConfigStore{}
+	t.Cleanup(func() { demoConfigPaths, demoConfig = savedPaths, savedStore })
+	return func(config string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDemoReloadRefreshesNamespaceDefaults(t *testing.T) {
+	saved := namespaceDefaults
+	namespaceDefaults = &namespaceDefaultsStore{}
+	t.Cleanup(func() { namespaceDefaults = saved })
+	write := useDemoConfig(t)
+
+	write("namespaceDefaults:\n  media:\n    category: Media\n")
+	if err := demoConfig.load(); err != nil {
+		t.Fatal(err)
+	}
+	if got := namespaceDefaults.get("media").Category; got != "Media" {
+		t.Fatalf("category after first load = %q, want Media", got)
+	}
+
+	write("namespaceDefaults:\n  media:\n    category: Streaming\n")
+	if err := demoConfig.load(); err != nil {
+		t.Fatal(err)
+	}
+	if got := namespaceDefaults.get("media").Category; got != "Streaming" {
+		t.Errorf("category after reload = %q, want Streaming", got)
+	}
+
+	write("ingresses: []\n")
+	if err := demoConfig.load(); err != nil {
+		t.Fatal(err)
+	}
+	if got := namespaceDefaults.get("media").Category; got != "" {
+		t.Errorf("category after removing the defaults = %q, want none", got)
+	}
+}
+
+func TestDemoReloadKeepsNamespaceDefaultsFile(t *testing.T) {
+	saved := namespaceDefaults
+	namespaceDefaults = &namespaceDefaultsStore{}
+	t.Cleanup(func() { namespaceDefaults = saved })
+
+	file := filepath.Join(t.TempDir(), "defaults.yaml")
+	if err := os.WriteFile(file, []byte("namespaceDefaults:\n  media:\n    category: From file\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadNamespaceDefaults(file); err != nil {
+		t.Fatal(err)
+	}
+
+	write := useDemoConfig(t)
+	write("namespaceDefaults:\n  media:\n    category: From demo\n")
+	for i := 0; i < 2; i++ {
+		if err := demoConfig.load(); err != nil {
+			t.Fatal(err)
+		}
+		if got := namespaceDefaults.get("media").Category; got != "From file" {
+			t.Errorf("category after demo load %d = %q, want From file", i+1, got)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// descriptionFormat selects how descriptions are rendered into DescriptionHTML, set by
+// DESCRIPTION_FORMAT; only "markdown" renders anything
+var descriptionFormat string
+
+var (
+	markdownCode   = regexp.MustCompile("`([^`]+)`")
+	markdownLink   = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	markdownStrong = regexp.MustCompile(`\*\*([^*]+)\*\*|__([^_]+)__`)
+	markdownEm     = regexp.MustCompile(`\*([^*]+)\*|\b_([^_]+)_\b`)
+)
+
+// renderDescription returns the description as HTML when DESCRIPTION_FORMAT=markdown,
+// or "" otherwise
+func renderDescription(description string) string {
+	if descriptionFormat != "markdown" || description == "" {
+		return ""
+	}
+	return renderMarkdown(description)
+}
+
+// renderMarkdown renders the inline markdown subset descriptions need: `code`,
+// **strong**, *emphasis* and [links](https://...). The input is HTML-escaped first
+// and only those few tags are generated, so annotations can't inject markup; link
+// targets must be http(s) or the link is reduced to its text.
+func renderMarkdown(src string) string {
+	text := html.EscapeString(src)
+
+	// Finished fragments are parked behind placeholders so later rules can't rewrite them
+	var parked []string
+	park := func(fragment string) string {
+		parked = append(parked, fragment)
+		return fmt.Sprintf("\x00%d\x00", len(parked)-1)
+	}
+
+	text = markdownCode.ReplaceAllStringFunc(text, func(m string) string {
+		return park("<code>" + markdownCode.FindStringSubmatch(m)[1] + "</code>")
+	})
+	text = markdownLink.ReplaceAllStringFunc(text, func(m string) string {
+		parts := markdownLink.FindStringSubmatch(m)
+		label := renderEmphasis(parts[1])
+		href := html.UnescapeString(parts[2])
+		if u, err := url.Parse(href); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return park(label)
+		}
+		return park(`<a href="` + html.EscapeString(href) + `" target="_blank" rel="noopener noreferrer nofollow">` + label + "</a>")
+	})
+	text = renderEmphasis(text)
+	text = strings.ReplaceAll(text, "\n", "<br>")
+
+	for i, fragment := range parked {
+		text = strings.Replace(text, fmt.Sprintf("\x00%d\x00", i), fragment, 1)
+	}
+	return text
+}
+
+// renderEmphasis turns **strong** and *emphasis* (or their underscore forms) into tags
+func renderEmphasis(text string) string {
+	text = markdownStrong.ReplaceAllString(text, "<strong>$1$2</strong>")
+	return markdownEm.ReplaceAllString(text, "<em>$1$2</em>")
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	appsRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "portal_apps_requests_total",
+		Help: "Requests to /api/apps by HTTP status code.",
+	}, []string{"code"})
+
+	k8sListDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "portal_kubernetes_list_duration_seconds",
+		Help:    "Latency of Kubernetes API List requests.",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	ingressFetchErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "portal_ingress_fetch_errors_total",
+		Help: "Failed Kubernetes API List requests.",
+	})
+
+	discoveredIngresses = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "portal_discovered_ingresses",
+		Help: "Ingresses seen during the last discovery.",
+	})
+
+	enabledAppsGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "portal_enabled_apps",
+		Help: "Apps enabled for the portal during the last discovery.",
+	})
+
+	appUp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "portal_app_up",
+		Help: "Whether the app answered its last health check (1 up, 0 down).",
+	}, []string{"app", "url"})
+
+	appCheckDuration = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "portal_app_health_check_duration_seconds",
+		Help: "Duration of the last health check per app.",
+	}, []string{"app", "url"})
+
+	backgroundFetchesInFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "portal_background_fetches_in_flight",
+		Help: "Background HTTP fetches currently running, by kind.",
+	}, []string{"kind"})
+
+	// appSeries tracks the label sets currently exported so vanished apps can be removed
+	appSeries = struct {
+		sync.Mutex
+		labels map[[2]string]struct{}
+	}{labels: make(map[[2]string]struct{})}
+)
+
+// registerMetrics registers every portal collector with the default registry
+func registerMetrics() {
+	prometheus.MustRegister(
+		appsRequests,
+		k8sListDuration,
+		ingressFetchErrors,
+		discoveredIngresses,
+		enabledAppsGauge,
+		appUp,
+		appCheckDuration,
+		backgroundFetchesInFlight,
+	)
+}
+
+// statusRecorder captures the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// countAppsRequests counts /api/apps responses by status code
+func countAppsRequests(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(rec, r)
+		appsRequests.WithLabelValues(strconv.Itoa(rec.status)).Inc()
+	})
+}
+
+// listMetricsTransport times Kubernetes List requests and counts failed ones; watches
+// are long-lived and excluded so they don't skew the histogram
+type listMetricsTransport struct {
+	next http.RoundTripper
+}
+
+func (t *listMetricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet || req.URL.Query().Get("watch") == "true" || !isListPath(req.URL.Path) {
+		return t.next.RoundTrip(req)
+	}
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	k8sListDuration.Observe(time.Since(start).Seconds())
+	if err != nil || resp.StatusCode >= http.StatusBadRequest {
+		ingressFetchErrors.Inc()
+	}
+	return resp, err
+}
+
+// isListPath reports whether an API path addresses a resource collection we list:
+// ingresses, the enabled apps ConfigMap, or a dynamicSources resource
+func isListPath(path string) bool {
+	resource := path[strings.LastIndex(path, "/")+1:]
+	if resource == "ingresses" || resource == "configmaps" {
+		return true
+	}
+	for _, gvr := range dynamicSources {
+		if resource == gvr.Resource {
+			return true
+		}
+	}
+	return false
+}
+
+// recordAppHealth exports the latest health results and drops series for apps
+// that are no longer discovered
+func recordAppHealth(apps []App, results map[string]appHealth) {
+	appSeries.Lock()
+	defer appSeries.Unlock()
+
+	current := make(map[[2]string]struct{})
+	for _, app := range apps {
+		result, ok := results[healthTarget(app)]
+		if !ok {
+			continue
+		}
+		labels := [2]string{app.Title, app.URL}
+		current[labels] = struct{}{}
+
+		up := 0.0
+		if result.Status == healthUp {
+			up = 1
+		}
+		appUp.WithLabelValues(labels[0], labels[1]).Set(up)
+		appCheckDuration.WithLabelValues(labels[0], labels[1]).Set(result.Duration.Seconds())
+	}
+
+	for labels := range appSeries.labels {
+		if _, ok := current[labels]; !ok {
+			appUp.DeleteLabelValues(labels[0], labels[1])
+			appCheckDuration.DeleteLabelValues(labels[0], labels[1])
+		}
+	}
+	appSeries.labels = current
+}
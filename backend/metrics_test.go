@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestIsListPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/apis/networking.k8s.io/v1/ingresses", true},
+		{"/apis/networking.k8s.io/v1/namespaces/media/ingresses", true},
+		{"/apis/extensions/v1beta1/namespaces/media/ingresses", true},
+		{"/api/v1/namespaces/portal/configmaps", true},
+		{"/apis/gateway.networking.k8s.io/v1/httproutes", true},
+		{"/apis/gateway.networking.k8s.io/v1/namespaces/media/httproutes", true},
+		{"/apis/traefik.io/v1alpha1/ingressroutes", true},
+		{"/apis/traefik.io/v1alpha1/namespaces/media/ingressroutes", true},
+		{"/apis/networking.k8s.io/v1/namespaces/media/ingresses/jellyfin", false},
+		{"/api/v1/namespaces/portal/secrets", false},
+		{"/apis/gateway.networking.k8s.io/v1/gateways", false},
+		{"/version", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := isListPath(tt.path); got != tt.want {
+			t.Errorf("isListPath(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
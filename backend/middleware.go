@@ -0,0 +1,160 @@
+package main
+
+import (
+	"compress/gzip"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// requestTimeout bounds how long an API handler may run before the client gets a 503
+var requestTimeout = 30 * time.Second
+
+// withTimeout wraps an API handler in http.TimeoutHandler, answering with a JSON 503
+// when the handler does not finish in time. Long-lived streams must not use it.
+func withTimeout(h http.HandlerFunc) http.Handler {
+	timeout := http.TimeoutHandler(h, requestTimeout, `{"error":"request timed out"}`)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Set before delegating so the timeout body is labelled as JSON too
+		w.Header().Set("Content-Type", "application/json")
+		timeout.ServeHTTP(w, r)
+	})
+}
+
+// maxBodyBytes caps request bodies; the API only accepts small JSON documents
+var maxBodyBytes int64 = 64 << 10
+
+// withBodyLimit stops handlers from reading more than MAX_BODY_BYTES of a request body
+func withBodyLimit(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Body != nil {
+			r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// incompressibleTypes are content types from getContentType that are already compressed
+var incompressibleTypes = map[string]bool{
+	"image/png":    true,
+	"image/jpeg":   true,
+	"image/x-icon": true,
+}
+
+// withGzip compresses responses for clients that send Accept-Encoding: gzip, leaving
+// already-compressed content types alone
+func withGzip(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if r.Method == http.MethodHead || !acceptsGzip(r) {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		gw := &gzipResponseWriter{ResponseWriter: w}
+		defer gw.close()
+		h.ServeHTTP(gw, r)
+	})
+}
+
+// acceptsGzip reports whether the request's Accept-Encoding allows gzip
+func acceptsGzip(r *http.Request) bool {
+	for _, enc := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(enc), ";")
+		if strings.EqualFold(strings.TrimSpace(name), "gzip") {
+			return strings.ReplaceAll(params, " ", "") != "q=0"
+		}
+	}
+	return false
+}
+
+// gzipResponseWriter decides on compression when the headers are written, once the
+// handler has set the content type
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	gz          *gzip.Writer
+	wroteHeader bool
+}
+
+func (g *gzipResponseWriter) WriteHeader(code int) {
+	if g.wroteHeader {
+		return
+	}
+	g.wroteHeader = true
+
+	header := g.Header()
+	contentType, _, _ := strings.Cut(header.Get("Content-Type"), ";")
+	if code != http.StatusNoContent && code != http.StatusNotModified &&
+		header.Get("Content-Encoding") == "" && !incompressibleTypes[strings.TrimSpace(contentType)] {
+		// The handler's length describes the uncompressed body
+		header.Del("Content-Length")
+		header.Set("Content-Encoding", "gzip")
+		g.gz = gzip.NewWriter(g.ResponseWriter)
+	}
+	g.ResponseWriter.WriteHeader(code)
+}
+
+func (g *gzipResponseWriter) Write(b []byte) (int, error) {
+	if !g.wroteHeader {
+		g.WriteHeader(http.StatusOK)
+	}
+	if g.gz != nil {
+		return g.gz.Write(b)
+	}
+	return g.ResponseWriter.Write(b)
+}
+
+// close flushes the gzip stream, if one was started
+func (g *gzipResponseWriter) close() {
+	if g.gz != nil {
+		g.gz.Close()
+	}
+}
+
+// withRecovery turns a handler panic into a logged stack trace and a JSON 500, so one
+// malformed object can't take the connection down with it
+func withRecovery(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+			// ErrAbortHandler is net/http's sanctioned way to abort a response
+			if err == http.ErrAbortHandler {
+				panic(err)
+			}
+			log.Printf("ERROR: panic serving %s %s request_id=%s: %v\n%s", r.Method, r.URL.Path, requestID(r.Context()), err, debug.Stack())
+			w.Header().Set("Content-Type", "application/json")
+			http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+		}()
+		h.ServeHTTP(w, r)
+	})
+}
+
+// defaultCSP allows the bundled assets plus app icons from any http(s) origin or data:
+// URI (generated letter icons); inline styles stay allowed for style attributes
+const defaultCSP = "default-src 'self'; img-src 'self' https: http: data:; style-src 'self' 'unsafe-inline'; script-src 'self'; connect-src 'self'; base-uri 'self'; form-action 'self'"
+
+var (
+	// frameOptions is the X-Frame-Options value, SAMEORIGIN or DENY
+	frameOptions = "SAMEORIGIN"
+	// contentSecurityPolicy is the Content-Security-Policy value; empty disables it
+	contentSecurityPolicy = defaultCSP
+)
+
+// withSecurityHeaders sets the browser hardening headers on every response
+func withSecurityHeaders(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := w.Header()
+		header.Set("X-Content-Type-Options", "nosniff")
+		header.Set("X-Frame-Options", frameOptions)
+		header.Set("Referrer-Policy", "strict-origin-when-cross-origin")
+		if contentSecurityPolicy != "" {
+			header.Set("Content-Security-Policy", contentSecurityPolicy)
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"net/http/pprof"
+)
+
+// registerPprof exposes the runtime profiles under /debug/pprof/, set by ENABLE_PPROF=true.
+// Profiles reveal command lines and memory contents and a CPU profile ties up a
+// goroutine for its whole duration, so only enable this behind the auth proxy.
+func registerPprof(mux *http.ServeMux) {
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	log.Printf("WARNING: pprof endpoints enabled under /debug/pprof/; keep them behind the auth proxy")
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// rateLimitIdle is how long a client's bucket is kept after its last request
+const rateLimitIdle = 10 * time.Minute
+
+// trustForwardedFor keys rate limits by X-Forwarded-For instead of the peer address;
+// only enable it behind a proxy that sets the header
+var trustForwardedFor bool
+
+// trustedProxyHops is how many proxies in front of the portal append to
+// X-Forwarded-For, set by RATE_LIMIT_TRUSTED_HOPS; the client is the entry the
+// outermost of them added
+var trustedProxyHops = 1
+
+// ipRateLimiter keeps one token bucket per client IP
+type ipRateLimiter struct {
+	mu      sync.Mutex
+	limit   rate.Limit
+	burst   int
+	clients map[string]*clientBucket
+	swept   time.Time
+}
+
+type clientBucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// newIPRateLimiter allows each client perSecond requests with bursts of up to burst
+func newIPRateLimiter(perSecond float64, burst int) *ipRateLimiter {
+	return &ipRateLimiter{
+		limit:   rate.Limit(perSecond),
+		burst:   burst,
+		clients: make(map[string]*clientBucket),
+		swept:   time.Now(),
+	}
+}
+
+// rateLimiterFromEnv builds a limiter from <prefix> (requests per second) and
+// <prefix>_BURST; it returns nil, disabling the limit, when <prefix> is unset
+func rateLimiterFromEnv(prefix string) *ipRateLimiter {
+	value := os.Getenv(prefix)
+	if value == "" {
+		return nil
+	}
+	perSecond, err := strconv.ParseFloat(value, 64)
+	if err != nil || perSecond <= 0 {
+		log.Printf("WARNING: Invalid %s %q, rate limiting disabled", prefix, value)
+		return nil
+	}
+	burst := parseIntEnv(prefix+"_BURST", int(math.Ceil(perSecond))*2)
+	log.Printf("Rate limiting %s: %g req/s per client, burst %d", prefix, perSecond, burst)
+	return newIPRateLimiter(perSecond, burst)
+}
+
+// allow takes a token for key, returning how long to wait when none is left
+func (l *ipRateLimiter) allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.swept) > rateLimitIdle {
+		for k, c := range l.clients {
+			if now.Sub(c.lastSeen) > rateLimitIdle {
+				delete(l.clients, k)
+			}
+		}
+		l.swept = now
+	}
+
+	c, ok := l.clients[key]
+	if !ok {
+		c = &clientBucket{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.clients[key] = c
+	}
+	c.lastSeen = now
+
+	reservation := c.limiter.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return false, delay
+	}
+	return true, 0
+}
+
+// clientIP returns the address a request is rate limited by. With trustForwardedFor
+// it is the X-Forwarded-For entry trustedProxyHops from the right: proxies append to
+// the header, so entries further left are whatever the client sent and can't be
+// trusted. Missing or malformed entries fall back to the peer address.
+func clientIP(r *http.Request) string {
+	if trustForwardedFor {
+		if ip := forwardedClientIP(r.Header.Values("X-Forwarded-For"), trustedProxyHops); ip != "" {
+			return ip
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// forwardedClientIP picks the entry hops from the right of the X-Forwarded-For
+// header lines, or "" when there are fewer entries or it is not an IP address
+func forwardedClientIP(headers []string, hops int) string {
+	var entries []string
+	for _, header := range headers {
+		for _, entry := range strings.Split(header, ",") {
+			entries = append(entries, strings.TrimSpace(entry))
+		}
+	}
+	if hops < 1 || len(entries) < hops {
+		return ""
+	}
+	ip := net.ParseIP(entries[len(entries)-hops])
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
+
+// withRateLimit answers 429 with Retry-After once a client exhausts its bucket; a
+// nil limiter passes every request through
+func withRateLimit(l *ipRateLimiter, h http.Handler) http.Handler {
+	if l == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := clientIP(r)
+		if ok, wait := l.allow(ip); !ok {
+			if debugMode {
+				log.Printf("DEBUG: Rate limited %s %s from %s", r.Method, r.URL.Path, ip)
+			}
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			w.Header().Set("Content-Type", "application/json")
+			http.Error(w, `{"error":"rate limit exceeded"}`, http.StatusTooManyRequests)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
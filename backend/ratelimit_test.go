@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name      string
+		trust     bool
+		hops      int
+		forwarded []string
+		want      string
+	}{
+		{name: "peer address without trust", forwarded: []string{"203.0.113.9"}, want: "192.0.2.1"},
+		{name: "proxy-added entry", trust: true, hops: 1, forwarded: []string{"203.0.113.9"}, want: "203.0.113.9"},
+		{name: "spoofed entries are ignored", trust: true, hops: 1, forwarded: []string{"10.0.0.1, 203.0.113.9"}, want: "203.0.113.9"},
+		{name: "multiple header lines", trust: true, hops: 1, forwarded: []string{"10.0.0.1", "203.0.113.9"}, want: "203.0.113.9"},
+		{name: "two trusted hops", trust: true, hops: 2, forwarded: []string{"10.0.0.1, 203.0.113.9, 198.51.100.7"}, want: "203.0.113.9"},
+		{name: "fewer entries than hops", trust: true, hops: 2, forwarded: []string{"203.0.113.9"}, want: "192.0.2.1"},
+		{name: "not an IP", trust: true, hops: 1, forwarded: []string{"junk"}, want: "192.0.2.1"},
+		{name: "no header", trust: true, hops: 1, want: "192.0.2.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			savedTrust, savedHops := trustForwardedFor, trustedProxyHops
+			trustForwardedFor, trustedProxyHops = tt.trust, tt.hops
+			defer func() { trustForwardedFor, trustedProxyHops = savedTrust, savedHops }()
+
+			r := httptest.NewRequest("GET", "/api/apps", nil)
+			r.RemoteAddr = "192.0.2.1:4321"
+			for _, value := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+			if got := clientIP(r); got != tt.want {
+				t.Errorf("clientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
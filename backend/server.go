@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// shutdownTimeout is how long in-flight requests get to finish after SIGTERM/SIGINT
+var shutdownTimeout = 10 * time.Second
+
+// Server limits guard the internet-facing listener against slow or oversized clients.
+// WriteTimeout must outlive REQUEST_TIMEOUT; app streams clear it for themselves.
+var (
+	readHeaderTimeout = 10 * time.Second
+	readTimeout       = 30 * time.Second
+	writeTimeout      = 60 * time.Second
+	idleTimeout       = 120 * time.Second
+	maxHeaderBytes    = 64 << 10
+)
+
+// tlsCertFile and tlsKeyFile switch the server to HTTPS when both are set
+var tlsCertFile, tlsKeyFile string
+
+// shuttingDown is closed when the server starts shutting down so long-lived
+// streams can end instead of holding the drain open
+var shuttingDown = make(chan struct{})
+
+// connTracker counts connections by state so shutdown can report what it drained
+type connTracker struct {
+	mu     sync.Mutex
+	states map[net.Conn]http.ConnState
+	active atomic.Int64
+}
+
+func newConnTracker() *connTracker {
+	return &connTracker{states: make(map[net.Conn]http.ConnState)}
+}
+
+// track is the http.Server ConnState hook
+func (t *connTracker) track(conn net.Conn, state http.ConnState) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.states[conn] == http.StateActive {
+		t.active.Add(-1)
+	}
+	switch state {
+	case http.StateHijacked, http.StateClosed:
+		delete(t.states, conn)
+		return
+	case http.StateActive:
+		t.active.Add(1)
+	}
+	t.states[conn] = state
+}
+
+// serve runs the server until SIGTERM or SIGINT, then shuts it down gracefully
+func serve(server *http.Server) {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	server.ReadHeaderTimeout = readHeaderTimeout
+	server.ReadTimeout = readTimeout
+	server.WriteTimeout = writeTimeout
+	server.IdleTimeout = idleTimeout
+	server.MaxHeaderBytes = maxHeaderBytes
+
+	conns := newConnTracker()
+	server.ConnState = conns.track
+	server.RegisterOnShutdown(func() { close(shuttingDown) })
+
+	// Bind before serving so the logged address is the resolved one (e.g. for port 0)
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		log.Fatalf("Failed to listen on %s: %v", server.Addr, err)
+	}
+	errCh := make(chan error, 1)
+	if tlsCertFile != "" {
+		server.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		log.Printf("Listening on %s (TLS)", listener.Addr())
+		go func() {
+			errCh <- server.ServeTLS(listener, tlsCertFile, tlsKeyFile)
+		}()
+	} else {
+		log.Printf("Listening on %s", listener.Addr())
+		go func() {
+			errCh <- server.Serve(listener)
+		}()
+	}
+
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Server error: %v", err)
+		}
+		return
+	case <-ctx.Done():
+	}
+	stop()
+
+	inFlight := conns.active.Load()
+	log.Printf("Shutdown signal received, draining %d active connections (timeout=%s)", inFlight, shutdownTimeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("WARNING: Graceful shutdown incomplete, %d connections still active: %v", conns.active.Load(), err)
+		return
+	}
+	log.Printf("Server stopped, drained %d connections", inFlight)
+}
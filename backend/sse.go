@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// streamInterval is how often the shared poller re-reads apps for stream subscribers;
+// in Kubernetes mode informer events also push changes immediately
+var streamInterval = 15 * time.Second
+
+// maxStreams caps concurrently connected streams, set by MAX_STREAMS
+var maxStreams = 100
+
+// appsUpdates fans discovered app lists out to every connected stream
+var appsUpdates = newAppsHub()
+
+// appsHub polls discovery while streams are connected and broadcasts changes
+type appsHub struct {
+	mu          sync.Mutex
+	subscribers map[chan []App]struct{}
+	last        []byte
+	changed     chan struct{}
+}
+
+func newAppsHub() *appsHub {
+	return &appsHub{
+		subscribers: make(map[chan []App]struct{}),
+		changed:     make(chan struct{}, 1),
+	}
+}
+
+// notify asks the hub to re-read apps now instead of waiting for the next poll;
+// bursts of notifications collapse into a single refresh
+func (h *appsHub) notify() {
+	select {
+	case h.changed <- struct{}{}:
+	default:
+	}
+}
+
+// subscribe registers a new stream, or returns false when maxStreams are already
+// connected; the channel only ever holds the latest app list
+func (h *appsHub) subscribe() (chan []App, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.subscribers) >= maxStreams {
+		return nil, false
+	}
+	ch := make(chan []App, 1)
+	h.subscribers[ch] = struct{}{}
+	return ch, true
+}
+
+// unsubscribe removes a stream once its client has gone away
+func (h *appsHub) unsubscribe(ch chan []App) {
+	h.mu.Lock()
+	delete(h.subscribers, ch)
+	h.mu.Unlock()
+}
+
+// publish sends apps to every subscriber if they differ from the last broadcast
+func (h *appsHub) publish(apps []App) {
+	payload, err := json.Marshal(apps)
+	if err != nil {
+		log.Printf("ERROR encoding apps for stream: %v", err)
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if bytes.Equal(payload, h.last) {
+		return
+	}
+	h.last = payload
+
+	for ch := range h.subscribers {
+		// Drop a pending update the subscriber hasn't consumed yet so slow clients never block the hub
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- apps
+	}
+}
+
+// active reports whether any stream is currently connected
+func (h *appsHub) active() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subscribers) > 0
+}
+
+// run re-reads apps on every tick or change notification while at least one stream
+// is connected
+func (h *appsHub) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-h.changed:
+		}
+		if !h.active() {
+			continue
+		}
+		apps, err := fetchApps(context.Background())
+		if err != nil {
+			log.Printf("ERROR fetching apps for stream: %v", err)
+			continue
+		}
+		h.publish(apps)
+	}
+}
+
+// handleAppsStream pushes the caller's filtered app list as Server-Sent Events whenever it changes
+func handleAppsStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		w.Header().Set("Content-Type", "application/json")
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		w.Header().Set("Content-Type", "application/json")
+		http.Error(w, `{"error":"streaming unsupported"}`, http.StatusInternalServerError)
+		return
+	}
+
+	userGroups := getUserGroups(r)
+	user := getUserName(r)
+	accepted := acceptedLanguages(r)
+
+	updates, ok := appsUpdates.subscribe()
+	if !ok {
+		log.Printf("WARNING: Rejecting apps stream, %d streams already open: remote_addr=%s", maxStreams, r.RemoteAddr)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", "30")
+		http.Error(w, `{"error":"too many streams"}`, http.StatusServiceUnavailable)
+		return
+	}
+	defer appsUpdates.unsubscribe(updates)
+	log.Printf("Apps stream opened: user_groups=%v remote_addr=%s", userGroups, r.RemoteAddr)
+
+	// Streams outlive WRITE_TIMEOUT by design, so lift the deadline for this connection
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		log.Printf("WARNING: Failed to clear write deadline for apps stream: %v", err)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	var last []byte
+	send := func(apps []App) error {
+		payload, err := json.Marshal(localizeApps(markFavorites(visibleApps(apps, userGroups), user), accepted))
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(payload, last) {
+			return nil
+		}
+		last = payload
+		if _, err := fmt.Fprintf(w, "event: apps\ndata: %s\n\n", payload); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	}
+
+	apps, err := fetchApps(r.Context())
+	if err != nil {
+		log.Printf("ERROR fetching apps for stream: %v", err)
+	} else if err := send(apps); err != nil {
+		log.Printf("Apps stream closed: remote_addr=%s err=%v", r.RemoteAddr, err)
+		return
+	}
+
+	keepalive := time.NewTicker(30 * time.Second)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			log.Printf("Apps stream closed: remote_addr=%s", r.RemoteAddr)
+			return
+		case <-shuttingDown:
+			log.Printf("Apps stream closed for shutdown: remote_addr=%s", r.RemoteAddr)
+			return
+		case apps := <-updates:
+			if err := send(apps); err != nil {
+				log.Printf("Apps stream closed: remote_addr=%s err=%v", r.RemoteAddr, err)
+				return
+			}
+		case <-keepalive.C:
+			// Comment lines keep idle connections open through proxies
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				log.Printf("Apps stream closed: remote_addr=%s err=%v", r.RemoteAddr, err)
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
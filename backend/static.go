@@ -0,0 +1,72 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"net/http"
+	"strings"
+)
+
+// staticETags maps each embedded static file to a strong ETag of its content. The
+// embedded FS never changes, so the hashes are computed once at startup.
+var staticETags map[string]string
+
+// loadStaticETags hashes every file in fsys
+func loadStaticETags(fsys fs.FS) (map[string]string, error) {
+	etags := make(map[string]string)
+	err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		content, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(content)
+		etags[path] = `"` + hex.EncodeToString(sum[:16]) + `"`
+		return nil
+	})
+	return etags, err
+}
+
+// staticCacheControl picks the caching policy for a static file: index.html must be
+// revalidated so new deploys show up, while Vite's content-hashed assets/ never change
+func staticCacheControl(path string) string {
+	switch {
+	case path == "index.html":
+		return "no-cache"
+	case strings.HasPrefix(path, "assets/"):
+		return "public, max-age=31536000, immutable"
+	default:
+		return "public, max-age=3600"
+	}
+}
+
+// etagMatches reports whether an If-None-Match header matches etag
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// writeStaticCacheHeaders sets ETag and Cache-Control for path, returning true when
+// the client's copy is current and a 304 has been sent instead of the body
+func writeStaticCacheHeaders(w http.ResponseWriter, r *http.Request, path string) bool {
+	etag, ok := staticETags[path]
+	if !ok {
+		return false
+	}
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", staticCacheControl(path))
+
+	if match := r.Header.Get("If-None-Match"); match != "" && etagMatches(match, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var (
+	// traefikHostRule matches Host(`a.example.com`, `b.example.com`) in a route match
+	traefikHostRule = regexp.MustCompile("Host\\(([^)]*)\\)")
+	// traefikPathPrefixRule matches PathPrefix(`/app`) in a route match
+	traefikPathPrefixRule = regexp.MustCompile("PathPrefix\\(\\s*`([^`]*)`")
+	// traefikBacktick extracts the backtick-quoted values of a matcher
+	traefikBacktick = regexp.MustCompile("`([^`]*)`")
+)
+
+// cachedIngressRoutes returns every Traefik IngressRoute in the informer stores
+func (c *kubeCluster) cachedIngressRoutes() []*unstructured.Unstructured {
+	return c.cachedObjects(sourceIngressRoute)
+}
+
+// ingressRouteHosts returns one route per distinct host named by Host() matchers in
+// a Traefik IngressRoute, using https when the route has a tls section like
+// getIngressURL does for Ingress TLS
+func ingressRouteHosts(route *unstructured.Unstructured) []appRoute {
+	scheme := "http://"
+	if tls, ok, _ := unstructured.NestedFieldNoCopy(route.Object, "spec", "tls"); ok && tls != nil {
+		scheme = "https://"
+	}
+
+	rules, _, _ := unstructured.NestedSlice(route.Object, "spec", "routes")
+
+	var routes []appRoute
+	seen := make(map[string]bool)
+	for _, r := range rules {
+		rule, ok := r.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		match, _, _ := unstructured.NestedString(rule, "match")
+
+		path := ""
+		if m := traefikPathPrefixRule.FindStringSubmatch(match); m != nil {
+			path = cleanRoutePath(m[1])
+		}
+		for _, host := range traefikHosts(match) {
+			key := strings.ToLower(host)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			routes = append(routes, appRoute{host: host, url: scheme + host + path})
+		}
+	}
+	return routes
+}
+
+// traefikHosts returns the hosts of every Host() matcher in a Traefik match rule
+func traefikHosts(match string) []string {
+	var hosts []string
+	for _, rule := range traefikHostRule.FindAllStringSubmatch(match, -1) {
+		for _, quoted := range traefikBacktick.FindAllStringSubmatch(rule[1], -1) {
+			if host := strings.TrimSpace(quoted[1]); host != "" {
+				hosts = append(hosts, host)
+			}
+		}
+	}
+	return hosts
+}
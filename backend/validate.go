@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// allowedURLSchemes lists the schemes accepted for app and URL annotation links
+	allowedURLSchemes = []string{"http", "https"}
+
+	// urlDNSCheck enables resolving each app host during validation
+	urlDNSCheck bool
+
+	// urlDNSTimeout bounds a single host lookup
+	urlDNSTimeout = 2 * time.Second
+)
+
+// validateAppURLs flags apps whose URL is malformed or, when enabled, whose host does not resolve
+func validateAppURLs(apps []App) {
+	var wg sync.WaitGroup
+	for i := range apps {
+		host, reason := parseAppURL(apps[i].URL)
+		if reason != "" || !urlDNSCheck {
+			setURLValidity(&apps[i], reason)
+			continue
+		}
+
+		wg.Add(1)
+		go func(app *App, host string) {
+			defer wg.Done()
+			setURLValidity(app, resolveHost(host))
+		}(&apps[i], host)
+	}
+	wg.Wait()
+}
+
+// setURLValidity records the validation outcome on the app and logs failures
+func setURLValidity(app *App, reason string) {
+	app.URLValid = reason == ""
+	app.URLError = reason
+	if reason != "" {
+		log.Printf("WARNING: Invalid app URL: title=%s url=%q reason=%s", app.Title, app.URL, reason)
+	}
+}
+
+// parseAppURL returns the host of an absolute URL, or a reason it is unusable
+func parseAppURL(raw string) (string, string) {
+	if raw == "" {
+		return "", "missing url"
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", "malformed url"
+	}
+	if !u.IsAbs() || u.Hostname() == "" {
+		return "", "url is not absolute"
+	}
+	if !schemeAllowed(u.Scheme) {
+		return "", "url scheme not allowed"
+	}
+	return u.Hostname(), ""
+}
+
+// parseURLSchemes parses the ALLOWED_URL_SCHEMES list
+func parseURLSchemes(value string) []string {
+	var schemes []string
+	for _, scheme := range strings.Split(value, ",") {
+		scheme = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(scheme), ":"))
+		if scheme != "" {
+			schemes = append(schemes, scheme)
+		}
+	}
+	return schemes
+}
+
+// schemeAllowed reports whether scheme is in ALLOWED_URL_SCHEMES
+func schemeAllowed(scheme string) bool {
+	for _, allowed := range allowedURLSchemes {
+		if strings.EqualFold(scheme, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkURLScheme rejects URLs that are unparseable, relative or use a disallowed scheme
+func checkURLScheme(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("malformed url")
+	}
+	if u.Scheme == "" {
+		return fmt.Errorf("url has no scheme")
+	}
+	if !schemeAllowed(u.Scheme) {
+		return fmt.Errorf("scheme %q not allowed", u.Scheme)
+	}
+	return nil
+}
+
+// resolveHost looks up host, returning a reason when it cannot be resolved
+func resolveHost(host string) string {
+	if net.ParseIP(host) != nil {
+		return ""
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), urlDNSTimeout)
+	defer cancel()
+
+	if _, err := net.DefaultResolver.LookupHost(ctx, host); err != nil {
+		return "host does not resolve"
+	}
+	return ""
+}
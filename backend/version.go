@@ -0,0 +1,24 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Build information, set at build time with
+// -ldflags "-X main.version=... -X main.gitCommit=... -X main.buildDate=..."
+var (
+	version   = "dev"
+	gitCommit = "unknown"
+	buildDate = "unknown"
+)
+
+// handleVersion reports which build is running
+func handleVersion(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"version":   version,
+		"gitCommit": gitCommit,
+		"buildDate": buildDate,
+	})
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"time"
+)
+
+// defaultFetchWorkers is the default number of concurrent background HTTP fetches
+const defaultFetchWorkers = 8
+
+// fetchTimeout bounds every background HTTP fetch (badges and health checks)
+var fetchTimeout = 5 * time.Second
+
+// backgroundFetches is the worker pool shared by the badge fetcher and health checker
+var backgroundFetches = &fetchPool{jobs: make(chan fetchJob)}
+
+// fetchJob is a unit of background work tagged with the feature that queued it
+type fetchJob struct {
+	kind string
+	run  func()
+}
+
+// fetchPool runs background fetches on a fixed number of goroutines so a large
+// cluster never opens more than that many concurrent connections
+type fetchPool struct {
+	jobs chan fetchJob
+}
+
+// start launches the pool's workers
+func (p *fetchPool) start(workers int) {
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+}
+
+// submit queues a job, blocking until a worker is free to take it
+func (p *fetchPool) submit(kind string, run func()) {
+	p.jobs <- fetchJob{kind: kind, run: run}
+}
+
+// work executes queued jobs, tracking how many of each kind are in flight
+func (p *fetchPool) work() {
+	for job := range p.jobs {
+		backgroundFetchesInFlight.WithLabelValues(job.kind).Inc()
+		job.run()
+		backgroundFetchesInFlight.WithLabelValues(job.kind).Dec()
+	}
+}
@@ -4,16 +4,57 @@ import { useState, useEffect } from 'react'
 function App() {
   const [apps, setApps] = useState([])
   const [loading, setLoading] = useState(true)
+  const [maintenance, setMaintenance] = useState(false)
+  const [branding, setBranding] = useState({ title: 'Redval Server' })
+
+  useEffect(() => {
+    fetch('/api/config')
+      .then(res => res.json())
+      .then(config => {
+        setBranding(config)
+        document.title = config.title
+        document.documentElement.dataset.theme = config.theme
+        if (config.accentColor) {
+          document.documentElement.style.setProperty('--accent', config.accentColor)
+        }
+      })
+      .catch(err => console.error(err))
+  }, [])
 
   useEffect(() => {
     fetchApps()
-    const interval = setInterval(fetchApps, 30000)
-    return () => clearInterval(interval)
+
+    // Prefer live updates over SSE, falling back to polling when unavailable
+    let interval
+    let source
+    if (window.EventSource) {
+      source = new EventSource('/api/apps/stream')
+      source.addEventListener('apps', event => {
+        setApps(JSON.parse(event.data) || [])
+        setLoading(false)
+      })
+      source.onerror = () => {
+        source.close()
+        if (!interval) {
+          interval = setInterval(fetchApps, 30000)
+        }
+      }
+    } else {
+      interval = setInterval(fetchApps, 30000)
+    }
+
+    return () => {
+      if (source) source.close()
+      if (interval) clearInterval(interval)
+    }
   }, [])
 
   const fetchApps = () => {
     fetch('/api/apps')
-      .then(res => res.json())
+      .then(res => {
+        setMaintenance(res.headers.get('X-Portal-Maintenance') === 'true')
+        return res.json()
+      })
       .then(data => {
         setApps(data || [])
         setLoading(false)
@@ -35,18 +76,28 @@ function App() {
   return (
     <div className="container">
       <header>
-        <h1>Redval Server</h1>
+        {branding.logoUrl && <img className="logo" src={branding.logoUrl} alt="" />}
+        <h1>{branding.title}</h1>
         <p className="subtitle">Quick access to your applications</p>
       </header>
+      {maintenance && (
+        <div className="maintenance-banner">
+          Maintenance in progress: the app list may be out of date.
+        </div>
+      )}
       <div className="grid">
         {apps.map((app, i) => (
-          <a key={i} href={app.url} className="card" target="_blank" rel="noopener noreferrer">
+          <a key={app.id || i} href={app.url} className="card" target={app.newTab ? "_blank" : undefined} rel="noopener noreferrer">
             <div className="card-icon">
               <img src={app.icon} alt={app.title} />
+              {app.badge > 0 && <span className="badge">{app.badge > 99 ? '99+' : app.badge}</span>}
             </div>
             <div className="card-content">
               <h3>{app.title}</h3>
-              <p className="description">{app.description || 'This is a good application'}</p>
+              {app.cluster && <span className="cluster">{app.cluster}</span>}
+              {app.descriptionHtml
+                ? <p className="description" dangerouslySetInnerHTML={{ __html: app.descriptionHtml }} />
+                : <p className="description">{app.description || 'This is a good application'}</p>}
             </div>
           </a>
         ))}